@@ -0,0 +1,209 @@
+// Package datadog translates Datadog agent trace payloads into the same structure produced by the otlp package
+package datadog
+
+import (
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/honeycombio/husky/otlp"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Payload versions, matching the /v0.4/traces and /v0.5/traces endpoints
+const (
+	V04 = "v0.4"
+	V05 = "v0.5"
+)
+
+const (
+	sampleRateKey       = "_sample_rate"
+	agentSampleRateKey  = "_dd.agent_psr"
+	samplingPriorityKey = "_sampling_priority_v1"
+	traceIDHighKey      = "_dd.p.tid"
+
+	// userKeep is the sampling priority set when a trace was kept manually, it is never sampled
+	userKeep = 2
+)
+
+// TranslateTraceReqFromReader translates the msgpack body sent by a Datadog tracer to the endpoint for version
+func TranslateTraceReqFromReader(body io.ReadCloser, ri otlp.RequestInfo, version string, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	var translate func([]byte, otlp.RequestInfo, ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error)
+	switch version {
+	case V04:
+		translate = TranslateV04Traces
+	case V05:
+		translate = TranslateV05Traces
+	default:
+		return nil, otlp.ErrInvalidContentType
+	}
+
+	data, err := otlp.ReadBody(body, ri.ContentEncoding)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return translate(data, ri, opts...)
+}
+
+// TranslateV04Traces translates a v0.4 payload, an array of traces that are arrays of span maps
+func TranslateV04Traces(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	traces, err := decodeV04(data)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return otlp.TranslateTraceReq(newTraceRequest(traces), ri, opts...)
+}
+
+// TranslateV05Traces translates a v0.5 payload, where strings are indexes into a string table
+func TranslateV05Traces(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	traces, err := decodeV05(data)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return otlp.TranslateTraceReq(newTraceRequest(traces), ri, opts...)
+}
+
+// newTraceRequest converts the traces into an OTLP request with a resource per service, in the
+// order the services were first seen. Spans without a service have no service.name
+func newTraceRequest(traces [][]*span) *collectorTrace.ExportTraceServiceRequest {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	librarySpans := make(map[string]*trace.ScopeSpans)
+	for _, spans := range traces {
+		traceIDHigh := getTraceIDHigh(spans)
+		sampleRate := getTraceSampleRate(spans)
+		for _, s := range spans {
+			library, ok := librarySpans[s.Service]
+			if !ok {
+				res := &resource.Resource{}
+				if s.Service != "" {
+					res.Attributes = []*common.KeyValue{otlp.NewAttribute("service.name", s.Service)}
+				}
+				library = &trace.ScopeSpans{}
+				librarySpans[s.Service] = library
+				request.ResourceSpans = append(request.ResourceSpans, &trace.ResourceSpans{
+					Resource:   res,
+					ScopeSpans: []*trace.ScopeSpans{library},
+				})
+			}
+			library.Spans = append(library.Spans, translateSpan(s, traceIDHigh, sampleRate))
+		}
+	}
+	return request
+}
+
+// translateSpan converts the span into an OTLP span, meta and metrics become its attributes
+// The trace's sample rate is passed on as a sampleRate attribute
+func translateSpan(s *span, traceIDHigh uint64, sampleRate int64) *trace.Span {
+	attrs := make([]*common.KeyValue, 0, len(s.Meta)+len(s.Metrics)+3)
+	for k, v := range s.Meta {
+		attrs = append(attrs, otlp.NewAttribute(k, v))
+	}
+	for k, v := range s.Metrics {
+		attrs = append(attrs, otlp.NewAttribute(k, v))
+	}
+	if s.Resource != "" {
+		attrs = append(attrs, otlp.NewAttribute("resource.name", s.Resource))
+	}
+	if s.Type != "" {
+		attrs = append(attrs, otlp.NewAttribute("span.type", s.Type))
+	}
+	if sampleRate > 0 {
+		attrs = append(attrs, otlp.NewAttribute("sampleRate", sampleRate))
+	}
+
+	otlpSpan := &trace.Span{
+		TraceId:           otlp.TraceIDBytes(traceIDHigh, s.TraceID),
+		SpanId:            otlp.SpanIDBytes(s.SpanID),
+		Name:              s.Name,
+		Kind:              getSpanKind(s),
+		StartTimeUnixNano: uint64(s.Start),
+		EndTimeUnixNano:   uint64(s.Start + s.Duration),
+		Attributes:        attrs,
+	}
+	if s.ParentID != 0 {
+		otlpSpan.ParentSpanId = otlp.SpanIDBytes(s.ParentID)
+	}
+	if s.Error != 0 {
+		message := s.Meta["error.msg"]
+		if message == "" {
+			message = s.Meta["error.message"]
+		}
+		otlpSpan.Status = &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: message}
+	}
+	return otlpSpan
+}
+
+// getTraceSampleRate returns the sample rate for every span of the trace, tracers only send the
+// sampling decision and rate on the first span of each trace chunk. Traces kept by the user are
+// never sampled so they have a rate of 1
+func getTraceSampleRate(spans []*span) int64 {
+	for _, s := range spans {
+		if priority, ok := s.Metrics[samplingPriorityKey]; ok && priority >= userKeep {
+			return 1
+		}
+	}
+	for _, s := range spans {
+		if rate, ok := sampleRateFromMetrics(s.Metrics); ok {
+			return rate
+		}
+	}
+	return 0
+}
+
+// sampleRateFromMetrics converts the tracer's sampling ratio, or the agent's if the tracer did
+// not sample, into a sample rate
+func sampleRateFromMetrics(metrics map[string]float64) (int64, bool) {
+	ratio, ok := metrics[sampleRateKey]
+	if !ok {
+		ratio, ok = metrics[agentSampleRateKey]
+	}
+	if !ok || ratio <= 0 || ratio > 1 {
+		return 0, false
+	}
+	rate := math.Round(1 / ratio)
+	if rate > math.MaxInt32 {
+		return math.MaxInt32, true
+	}
+	return int64(rate), true
+}
+
+// getSpanKind returns the kind from the span.kind tag, falling back to the span type
+func getSpanKind(s *span) trace.Span_SpanKind {
+	switch s.Meta["span.kind"] {
+	case "client":
+		return trace.Span_SPAN_KIND_CLIENT
+	case "server":
+		return trace.Span_SPAN_KIND_SERVER
+	case "producer":
+		return trace.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return trace.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return trace.Span_SPAN_KIND_INTERNAL
+	}
+	switch s.Type {
+	case "web":
+		return trace.Span_SPAN_KIND_SERVER
+	case "http", "grpc", "sql", "db", "cache", "redis", "memcached", "mongodb", "cassandra", "elasticsearch":
+		return trace.Span_SPAN_KIND_CLIENT
+	case "queue":
+		return trace.Span_SPAN_KIND_PRODUCER
+	}
+	return trace.Span_SPAN_KIND_UNSPECIFIED
+}
+
+// getTraceIDHigh returns the high 64 bits of a 128-bit trace ID, which tracers send as hex
+// in the _dd.p.tid tag of the first span of each trace chunk
+func getTraceIDHigh(spans []*span) uint64 {
+	for _, s := range spans {
+		if tid, ok := s.Meta[traceIDHighKey]; ok {
+			if high, err := strconv.ParseUint(tid, 16, 64); err == nil {
+				return high
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,258 @@
+package datadog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var testStartTime = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+type testSpan struct {
+	service, name, resource, spanType string
+	traceID, spanID, parentID         uint64
+	start, duration, err              int64
+	meta                              map[string]string
+	metrics                           map[string]float64
+}
+
+func newTestTraces() [][]testSpan {
+	start := testStartTime.UnixNano()
+	return [][]testSpan{
+		{
+			{
+				service: "web", name: "http.request", resource: "GET /users", spanType: "web",
+				traceID: 5208512171318403364, spanID: 5208512171318403364, start: start, duration: int64(2 * time.Millisecond),
+				meta:    map[string]string{"http.method": "GET", "_dd.p.tid": "5af7183fb1d4cf5f"},
+				metrics: map[string]float64{"_sample_rate": 0.5, "_sampling_priority_v1": 1},
+			},
+			{
+				service: "db", name: "postgres.query", resource: "SELECT 1", spanType: "sql",
+				traceID: 5208512171318403364, spanID: 2, parentID: 5208512171318403364, start: start, duration: int64(time.Millisecond), err: 1,
+				meta: map[string]string{"error.msg": "timeout"},
+			},
+		},
+		{
+			{
+				name: "job", traceID: 1, spanID: 1, start: start,
+				metrics: map[string]float64{"_sample_rate": 0.1, "_sampling_priority_v1": 2},
+			},
+		},
+	}
+}
+
+func encodeV04(traces [][]testSpan) []byte {
+	b := msgp.AppendArrayHeader(nil, uint32(len(traces)))
+	for _, spans := range traces {
+		b = msgp.AppendArrayHeader(b, uint32(len(spans)))
+		for _, s := range spans {
+			b = msgp.AppendMapHeader(b, 13)
+			b = msgp.AppendString(b, "service")
+			b = msgp.AppendString(b, s.service)
+			b = msgp.AppendString(b, "name")
+			b = msgp.AppendString(b, s.name)
+			b = msgp.AppendString(b, "resource")
+			b = msgp.AppendString(b, s.resource)
+			b = msgp.AppendString(b, "type")
+			b = msgp.AppendString(b, s.spanType)
+			b = msgp.AppendString(b, "trace_id")
+			b = msgp.AppendUint64(b, s.traceID)
+			b = msgp.AppendString(b, "span_id")
+			b = msgp.AppendUint64(b, s.spanID)
+			b = msgp.AppendString(b, "parent_id")
+			b = msgp.AppendUint64(b, s.parentID)
+			b = msgp.AppendString(b, "start")
+			b = msgp.AppendInt64(b, s.start)
+			b = msgp.AppendString(b, "duration")
+			b = msgp.AppendInt64(b, s.duration)
+			b = msgp.AppendString(b, "error")
+			b = msgp.AppendInt32(b, int32(s.err))
+			b = msgp.AppendString(b, "meta")
+			b = msgp.AppendMapHeader(b, uint32(len(s.meta)))
+			for k, v := range s.meta {
+				b = msgp.AppendString(b, k)
+				b = msgp.AppendString(b, v)
+			}
+			b = msgp.AppendString(b, "metrics")
+			b = msgp.AppendMapHeader(b, uint32(len(s.metrics)))
+			for k, v := range s.metrics {
+				b = msgp.AppendString(b, k)
+				b = msgp.AppendFloat64(b, v)
+			}
+			b = msgp.AppendString(b, "meta_struct")
+			b = msgp.AppendMapHeader(b, 0)
+		}
+	}
+	return b
+}
+
+func encodeV05(traces [][]testSpan) []byte {
+	var dict []string
+	index := map[string]uint32{}
+	ref := func(b []byte, s string) []byte {
+		i, ok := index[s]
+		if !ok {
+			i = uint32(len(dict))
+			index[s] = i
+			dict = append(dict, s)
+		}
+		return msgp.AppendUint32(b, i)
+	}
+
+	var body []byte
+	body = msgp.AppendArrayHeader(body, uint32(len(traces)))
+	for _, spans := range traces {
+		body = msgp.AppendArrayHeader(body, uint32(len(spans)))
+		for _, s := range spans {
+			body = msgp.AppendArrayHeader(body, 12)
+			body = ref(body, s.service)
+			body = ref(body, s.name)
+			body = ref(body, s.resource)
+			body = msgp.AppendUint64(body, s.traceID)
+			body = msgp.AppendUint64(body, s.spanID)
+			body = msgp.AppendUint64(body, s.parentID)
+			body = msgp.AppendInt64(body, s.start)
+			body = msgp.AppendInt64(body, s.duration)
+			body = msgp.AppendInt32(body, int32(s.err))
+			body = msgp.AppendMapHeader(body, uint32(len(s.meta)))
+			for k, v := range s.meta {
+				body = ref(body, k)
+				body = ref(body, v)
+			}
+			body = msgp.AppendMapHeader(body, uint32(len(s.metrics)))
+			for k, v := range s.metrics {
+				body = ref(body, k)
+				body = msgp.AppendFloat64(body, v)
+			}
+			body = ref(body, s.spanType)
+		}
+	}
+
+	b := msgp.AppendArrayHeader(nil, 2)
+	b = msgp.AppendArrayHeader(b, uint32(len(dict)))
+	for _, s := range dict {
+		b = msgp.AppendString(b, s)
+	}
+	return append(b, body...)
+}
+
+func TestTranslateTraces(t *testing.T) {
+	for version, data := range map[string][]byte{V04: encodeV04(newTestTraces()), V05: encodeV05(newTestTraces())} {
+		translate := TranslateV04Traces
+		if version == V05 {
+			translate = TranslateV05Traces
+		}
+		result, err := translate(data, otlp.RequestInfo{})
+		require.NoError(t, err, version)
+		require.Equal(t, 3, len(result.Batches), version)
+		assert.Equal(t, "web", result.Batches[0].Dataset)
+		assert.Equal(t, "db", result.Batches[1].Dataset)
+		assert.Equal(t, "unknown_service", result.Batches[2].Dataset)
+
+		root := result.Batches[0].Events[0]
+		assert.Equal(t, testStartTime, root.Timestamp)
+		assert.Equal(t, int32(2), root.SampleRate)
+		assert.Equal(t, "5af7183fb1d4cf5f48485a3953bb6124", root.Attributes["traceTraceID"])
+		assert.Equal(t, "48485a3953bb6124", root.Attributes["traceSpanID"])
+		assert.Equal(t, "server", root.Attributes["spanKind"])
+		assert.Equal(t, "http.request", root.Attributes["spanName"])
+		assert.Equal(t, 2.0, root.Attributes["durationMs"])
+		assert.Equal(t, testStartTime.Add(2*time.Millisecond).UnixNano(), root.Attributes["endTime"])
+		assert.Equal(t, false, root.Attributes["error"])
+		assert.Equal(t, map[string]interface{}{"service.name": "web"}, root.Attributes["resourceAttributes"])
+		spanAttrs := root.Attributes["spanAttributes"].(map[string]interface{})
+		assert.Equal(t, "GET /users", spanAttrs["resource.name"])
+		assert.Equal(t, "GET", spanAttrs["http.method"])
+		assert.Equal(t, 0.5, spanAttrs["_sample_rate"])
+		assert.Equal(t, "web", spanAttrs["span.type"])
+		_, ok := spanAttrs["sampleRate"]
+		assert.False(t, ok)
+
+		child := result.Batches[1].Events[0]
+		assert.Equal(t, root.Attributes["traceTraceID"], child.Attributes["traceTraceID"])
+		assert.Equal(t, "0000000000000002", child.Attributes["traceSpanID"])
+		assert.Equal(t, "48485a3953bb6124", child.Attributes["traceParentID"])
+		assert.Equal(t, "client", child.Attributes["spanKind"])
+		assert.Equal(t, trace.Status_STATUS_CODE_ERROR, child.Attributes["statusCode"])
+		assert.Equal(t, "timeout", child.Attributes["statusMessage"])
+		assert.Equal(t, true, child.Attributes["error"])
+		assert.Equal(t, int32(2), child.SampleRate)
+
+		kept := result.Batches[2].Events[0]
+		assert.Equal(t, "0000000000000001", kept.Attributes["traceTraceID"])
+		assert.Equal(t, "unspecified", kept.Attributes["spanKind"])
+		assert.Equal(t, map[string]interface{}{}, kept.Attributes["resourceAttributes"])
+		assert.Equal(t, int32(1), kept.SampleRate)
+	}
+}
+
+func TestTranslateTracesRequestDataset(t *testing.T) {
+	result, err := TranslateV04Traces(encodeV04(newTestTraces()), otlp.RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(result.Batches))
+	for _, batch := range result.Batches {
+		assert.Equal(t, "dataset", batch.Dataset)
+	}
+}
+
+func TestTranslateTracesHugeDeclaredLengths(t *testing.T) {
+	huge := []byte{0xdd, 0xff, 0xff, 0xff, 0xff}
+	hugeMap := []byte{0xdf, 0xff, 0xff, 0xff, 0xff}
+	v04Span := msgp.AppendMapHeader(msgp.AppendArrayHeader(msgp.AppendArrayHeader(nil, 1), 1), 1)
+	v05Span := msgp.AppendArrayHeader(nil, 2)
+	v05Span = msgp.AppendArrayHeader(v05Span, 1)
+	v05Span = msgp.AppendString(v05Span, "")
+	v05Span = msgp.AppendArrayHeader(msgp.AppendArrayHeader(v05Span, 1), 1)
+	v05Span = msgp.AppendArrayHeader(v05Span, 12)
+	for i := 0; i < 9; i++ {
+		v05Span = msgp.AppendUint32(v05Span, 0)
+	}
+
+	for name, tc := range map[string]struct {
+		translate func([]byte, otlp.RequestInfo, ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error)
+		body      []byte
+	}{
+		"v04 traces":  {TranslateV04Traces, huge},
+		"v04 spans":   {TranslateV04Traces, append(msgp.AppendArrayHeader(nil, 1), huge...)},
+		"v04 meta":    {TranslateV04Traces, append(msgp.AppendString(v04Span, "meta"), hugeMap...)},
+		"v04 metrics": {TranslateV04Traces, append(msgp.AppendString(v04Span, "metrics"), hugeMap...)},
+		"v05 strings": {TranslateV05Traces, append(msgp.AppendArrayHeader(nil, 2), huge...)},
+		"v05 traces":  {TranslateV05Traces, append(msgp.AppendArrayHeader(msgp.AppendArrayHeader(nil, 2), 0), huge...)},
+		"v05 meta":    {TranslateV05Traces, append(v05Span, hugeMap...)},
+	} {
+		_, err := tc.translate(tc.body, otlp.RequestInfo{})
+		assert.Equal(t, otlp.ErrFailedParseBody, err, name)
+	}
+}
+
+func TestTranslateV05TracesInvalidStringIndex(t *testing.T) {
+	b := msgp.AppendArrayHeader(nil, 2)
+	b = msgp.AppendArrayHeader(b, 0)
+	b = msgp.AppendArrayHeader(b, 1)
+	b = msgp.AppendArrayHeader(b, 1)
+	b = msgp.AppendArrayHeader(b, 12)
+	b = msgp.AppendUint32(b, 3)
+
+	_, err := TranslateV05Traces(b, otlp.RequestInfo{})
+	assert.Equal(t, otlp.ErrFailedParseBody, err)
+}
+
+func TestTranslateTraceReqFromReader(t *testing.T) {
+	data := encodeV04(newTestTraces())
+	result, err := TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{}, V04)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(result.Batches))
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{}, V05)
+	assert.Equal(t, otlp.ErrFailedParseBody, err)
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{}, "v0.3")
+	assert.Equal(t, otlp.ErrInvalidContentType, err)
+}
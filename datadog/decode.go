@@ -0,0 +1,348 @@
+package datadog
+
+import (
+	"errors"
+	"math"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+var errInvalidPayload = errors.New("invalid datadog trace payload")
+
+// span is a decoded Datadog span, shared by the v0.4 and v0.5 payload formats
+type span struct {
+	Service  string
+	Name     string
+	Resource string
+	Type     string
+	TraceID  uint64
+	SpanID   uint64
+	ParentID uint64
+	Start    int64
+	Duration int64
+	Error    int64
+	Meta     map[string]string
+	Metrics  map[string]float64
+}
+
+// sizeHint bounds a length read from the payload by the bytes left to decode, every element takes
+// at least one byte so larger lengths are invalid and must not be used to preallocate
+func sizeHint(n uint32, b []byte) int {
+	if uint64(n) > uint64(len(b)) {
+		return len(b)
+	}
+	return int(n)
+}
+
+// decodeV04 decodes a v0.4 payload, an array of traces where each trace is an array of span maps
+func decodeV04(b []byte) ([][]*span, error) {
+	numTraces, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	traces := make([][]*span, 0, sizeHint(numTraces, b))
+	for i := uint32(0); i < numTraces; i++ {
+		var numSpans uint32
+		if numSpans, b, err = msgp.ReadArrayHeaderBytes(b); err != nil {
+			return nil, err
+		}
+		trace := make([]*span, 0, sizeHint(numSpans, b))
+		for j := uint32(0); j < numSpans; j++ {
+			var s *span
+			if s, b, err = decodeV04Span(b); err != nil {
+				return nil, err
+			}
+			trace = append(trace, s)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
+func decodeV04Span(b []byte) (*span, []byte, error) {
+	numFields, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &span{}
+	for i := uint32(0); i < numFields; i++ {
+		var key []byte
+		if key, b, err = msgp.ReadStringZC(b); err != nil {
+			return nil, nil, err
+		}
+		switch string(key) {
+		case "service":
+			s.Service, b, err = readString(b)
+		case "name":
+			s.Name, b, err = readString(b)
+		case "resource":
+			s.Resource, b, err = readString(b)
+		case "type":
+			s.Type, b, err = readString(b)
+		case "trace_id":
+			s.TraceID, b, err = readUint64(b)
+		case "span_id":
+			s.SpanID, b, err = readUint64(b)
+		case "parent_id":
+			s.ParentID, b, err = readUint64(b)
+		case "start":
+			s.Start, b, err = readInt64(b)
+		case "duration":
+			s.Duration, b, err = readInt64(b)
+		case "error":
+			s.Error, b, err = readInt64(b)
+		case "meta":
+			s.Meta, b, err = readStringMap(b)
+		case "metrics":
+			s.Metrics, b, err = readFloatMap(b)
+		default:
+			b, err = msgp.Skip(b)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return s, b, nil
+}
+
+// decodeV05 decodes a v0.5 payload, an array holding a string table followed by the traces
+// Every span is an array of 12 elements and every string is an index into the string table
+func decodeV05(b []byte) ([][]*span, error) {
+	size, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if size != 2 {
+		return nil, errInvalidPayload
+	}
+
+	numStrings, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	dict := make([]string, 0, sizeHint(numStrings, b))
+	for i := uint32(0); i < numStrings; i++ {
+		var str string
+		if str, b, err = readString(b); err != nil {
+			return nil, err
+		}
+		dict = append(dict, str)
+	}
+
+	numTraces, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	traces := make([][]*span, 0, sizeHint(numTraces, b))
+	for i := uint32(0); i < numTraces; i++ {
+		var numSpans uint32
+		if numSpans, b, err = msgp.ReadArrayHeaderBytes(b); err != nil {
+			return nil, err
+		}
+		trace := make([]*span, 0, sizeHint(numSpans, b))
+		for j := uint32(0); j < numSpans; j++ {
+			var s *span
+			if s, b, err = decodeV05Span(b, dict); err != nil {
+				return nil, err
+			}
+			trace = append(trace, s)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
+func decodeV05Span(b []byte, dict []string) (*span, []byte, error) {
+	size, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if size != 12 {
+		return nil, nil, errInvalidPayload
+	}
+
+	s := &span{}
+	if s.Service, b, err = readDictString(b, dict); err != nil {
+		return nil, nil, err
+	}
+	if s.Name, b, err = readDictString(b, dict); err != nil {
+		return nil, nil, err
+	}
+	if s.Resource, b, err = readDictString(b, dict); err != nil {
+		return nil, nil, err
+	}
+	if s.TraceID, b, err = readUint64(b); err != nil {
+		return nil, nil, err
+	}
+	if s.SpanID, b, err = readUint64(b); err != nil {
+		return nil, nil, err
+	}
+	if s.ParentID, b, err = readUint64(b); err != nil {
+		return nil, nil, err
+	}
+	if s.Start, b, err = readInt64(b); err != nil {
+		return nil, nil, err
+	}
+	if s.Duration, b, err = readInt64(b); err != nil {
+		return nil, nil, err
+	}
+	if s.Error, b, err = readInt64(b); err != nil {
+		return nil, nil, err
+	}
+
+	var numMeta uint32
+	if numMeta, b, err = msgp.ReadMapHeaderBytes(b); err != nil {
+		return nil, nil, err
+	}
+	s.Meta = make(map[string]string, sizeHint(numMeta, b))
+	for i := uint32(0); i < numMeta; i++ {
+		var k, v string
+		if k, b, err = readDictString(b, dict); err != nil {
+			return nil, nil, err
+		}
+		if v, b, err = readDictString(b, dict); err != nil {
+			return nil, nil, err
+		}
+		s.Meta[k] = v
+	}
+
+	var numMetrics uint32
+	if numMetrics, b, err = msgp.ReadMapHeaderBytes(b); err != nil {
+		return nil, nil, err
+	}
+	s.Metrics = make(map[string]float64, sizeHint(numMetrics, b))
+	for i := uint32(0); i < numMetrics; i++ {
+		var k string
+		var v float64
+		if k, b, err = readDictString(b, dict); err != nil {
+			return nil, nil, err
+		}
+		if v, b, err = readFloat64(b); err != nil {
+			return nil, nil, err
+		}
+		s.Metrics[k] = v
+	}
+
+	if s.Type, b, err = readDictString(b, dict); err != nil {
+		return nil, nil, err
+	}
+	return s, b, nil
+}
+
+func readDictString(b []byte, dict []string) (string, []byte, error) {
+	i, b, err := readUint64(b)
+	if err != nil {
+		return "", nil, err
+	}
+	if i >= uint64(len(dict)) {
+		return "", nil, errInvalidPayload
+	}
+	return dict[i], b, nil
+}
+
+// readString reads a string, tracers send nil for empty strings
+func readString(b []byte) (string, []byte, error) {
+	switch msgp.NextType(b) {
+	case msgp.NilType:
+		b, err := msgp.ReadNilBytes(b)
+		return "", b, err
+	case msgp.BinType:
+		v, b, err := msgp.ReadBytesZC(b)
+		return string(v), b, err
+	}
+	return msgp.ReadStringBytes(b)
+}
+
+// readUint64 reads an ID, which tracers encode as any integer type
+func readUint64(b []byte) (uint64, []byte, error) {
+	switch msgp.NextType(b) {
+	case msgp.NilType:
+		b, err := msgp.ReadNilBytes(b)
+		return 0, b, err
+	case msgp.IntType:
+		i, b, err := msgp.ReadInt64Bytes(b)
+		return uint64(i), b, err
+	}
+	return msgp.ReadUint64Bytes(b)
+}
+
+func readInt64(b []byte) (int64, []byte, error) {
+	switch msgp.NextType(b) {
+	case msgp.NilType:
+		b, err := msgp.ReadNilBytes(b)
+		return 0, b, err
+	case msgp.UintType:
+		u, b, err := msgp.ReadUint64Bytes(b)
+		if u > math.MaxInt64 {
+			return 0, nil, errInvalidPayload
+		}
+		return int64(u), b, err
+	case msgp.Float32Type, msgp.Float64Type:
+		f, b, err := msgp.ReadFloat64Bytes(b)
+		return int64(f), b, err
+	}
+	return msgp.ReadInt64Bytes(b)
+}
+
+// readFloat64 reads a metric value, which some tracers encode as an integer
+func readFloat64(b []byte) (float64, []byte, error) {
+	switch msgp.NextType(b) {
+	case msgp.NilType:
+		b, err := msgp.ReadNilBytes(b)
+		return 0, b, err
+	case msgp.IntType:
+		i, b, err := msgp.ReadInt64Bytes(b)
+		return float64(i), b, err
+	case msgp.UintType:
+		u, b, err := msgp.ReadUint64Bytes(b)
+		return float64(u), b, err
+	}
+	return msgp.ReadFloat64Bytes(b)
+}
+
+func readStringMap(b []byte) (map[string]string, []byte, error) {
+	if msgp.NextType(b) == msgp.NilType {
+		b, err := msgp.ReadNilBytes(b)
+		return nil, b, err
+	}
+	size, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := make(map[string]string, sizeHint(size, b))
+	for i := uint32(0); i < size; i++ {
+		var k, v string
+		if k, b, err = readString(b); err != nil {
+			return nil, nil, err
+		}
+		if v, b, err = readString(b); err != nil {
+			return nil, nil, err
+		}
+		m[k] = v
+	}
+	return m, b, nil
+}
+
+func readFloatMap(b []byte) (map[string]float64, []byte, error) {
+	if msgp.NextType(b) == msgp.NilType {
+		b, err := msgp.ReadNilBytes(b)
+		return nil, b, err
+	}
+	size, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := make(map[string]float64, sizeHint(size, b))
+	for i := uint32(0); i < size; i++ {
+		var k string
+		var v float64
+		if k, b, err = readString(b); err != nil {
+			return nil, nil, err
+		}
+		if v, b, err = readFloat64(b); err != nil {
+			return nil, nil, err
+		}
+		m[k] = v
+	}
+	return m, b, nil
+}
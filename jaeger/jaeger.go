@@ -0,0 +1,260 @@
+// Package jaeger translates Jaeger batches into the same structure produced by the otlp package
+package jaeger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/honeycombio/husky/otlp"
+	"github.com/jaegertracing/jaeger/model"
+	jaegerconverter "github.com/jaegertracing/jaeger/model/converter/thrift/jaeger"
+	"github.com/jaegertracing/jaeger/proto-gen/api_v2"
+	jaegerthrift "github.com/jaegertracing/jaeger/thrift-gen/jaeger"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// TranslateTraceReqFromReader translates a Jaeger HTTP request body into Opsramp-friendly structure
+// RequestInfo.ContentType selects Thrift binary (application/x-thrift, as sent to /api/traces),
+// Thrift compact (application/vnd.apache.thrift.compact) or an api_v2 PostSpansRequest (application/x-protobuf)
+func TranslateTraceReqFromReader(body io.ReadCloser, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	var translate func([]byte, otlp.RequestInfo, ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error)
+	switch ri.ContentType {
+	case "application/x-thrift", "application/vnd.apache.thrift.binary":
+		translate = TranslateThriftBatch
+	case "application/vnd.apache.thrift.compact":
+		translate = TranslateThriftCompactBatch
+	case "application/x-protobuf", "application/protobuf":
+		translate = TranslateProtoBatch
+	default:
+		return nil, otlp.ErrInvalidContentType
+	}
+
+	data, err := otlp.ReadBody(body, ri.ContentEncoding)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return translate(data, ri, opts...)
+}
+
+// TranslateThriftBatch translates a Jaeger Batch serialised with the Thrift binary protocol
+func TranslateThriftBatch(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	transport := &thrift.TMemoryBuffer{Buffer: bytes.NewBuffer(data)}
+	return translateThrift(thrift.NewTBinaryProtocolConf(transport, nil), ri, opts)
+}
+
+// TranslateThriftCompactBatch translates a Jaeger Batch serialised with the Thrift compact protocol
+func TranslateThriftCompactBatch(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	transport := &thrift.TMemoryBuffer{Buffer: bytes.NewBuffer(data)}
+	return translateThrift(thrift.NewTCompactProtocolConf(transport, nil), ri, opts)
+}
+
+func translateThrift(protocol thrift.TProtocol, ri otlp.RequestInfo, opts []otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	batch := jaegerthrift.NewBatch()
+	if err := batch.Read(context.Background(), protocol); err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return TranslateBatch(model.Batch{
+		Spans:   jaegerconverter.ToDomain(batch.Spans, batch.Process),
+		Process: jaegerconverter.ToDomainProcess(batch.Process),
+	}, ri, opts...)
+}
+
+// TranslateProtoBatch translates a serialised api_v2 PostSpansRequest
+func TranslateProtoBatch(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	var request api_v2.PostSpansRequest
+	if err := request.Unmarshal(data); err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return TranslateBatch(request.Batch, ri, opts...)
+}
+
+// TranslateBatch translates a Jaeger batch, as received by the api_v2 gRPC collector service
+// Spans with their own Process use it in place of the batch's Process
+func TranslateBatch(batch model.Batch, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	return otlp.TranslateTraceReq(newTraceRequest(batch), ri, opts...)
+}
+
+// newTraceRequest converts the batch into an OTLP request with a resource per distinct process,
+// in the order the processes were first seen
+func newTraceRequest(batch model.Batch) *collectorTrace.ExportTraceServiceRequest {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	librarySpans := make(map[string]*trace.ScopeSpans)
+	for _, span := range batch.Spans {
+		if span == nil {
+			continue
+		}
+		process := span.Process
+		if process == nil {
+			process = batch.Process
+		}
+		var key []byte
+		if process != nil {
+			key, _ = process.Marshal()
+		}
+		library, ok := librarySpans[string(key)]
+		if !ok {
+			library = &trace.ScopeSpans{}
+			librarySpans[string(key)] = library
+			request.ResourceSpans = append(request.ResourceSpans, &trace.ResourceSpans{
+				Resource:   translateProcess(process),
+				ScopeSpans: []*trace.ScopeSpans{library},
+			})
+		}
+		library.Spans = append(library.Spans, translateSpan(span))
+	}
+	return request
+}
+
+// translateProcess returns the resource for the process, its tags and service.name
+func translateProcess(process *model.Process) *resource.Resource {
+	res := &resource.Resource{}
+	if process == nil {
+		return res
+	}
+	res.Attributes = appendTags(res.Attributes, process.Tags)
+	if process.ServiceName != "" {
+		res.Attributes = append(res.Attributes, otlp.NewAttribute("service.name", process.ServiceName))
+	}
+	return res
+}
+
+// translateSpan converts the span into an OTLP span, logs become span events
+// The first child-of reference in the same trace is the parent, all other references are links
+func translateSpan(span *model.Span) *trace.Span {
+	startTime := uint64(span.StartTime.UnixNano())
+	attrs := appendTags(make([]*common.KeyValue, 0, len(span.Tags)+1), span.Tags)
+	if sampleRate := getSampleRate(span.Tags); sampleRate > 0 {
+		attrs = append(attrs, otlp.NewAttribute("sampleRate", sampleRate))
+	}
+
+	otlpSpan := &trace.Span{
+		TraceId:           otlp.TraceIDBytes(span.TraceID.High, span.TraceID.Low),
+		SpanId:            otlp.SpanIDBytes(uint64(span.SpanID)),
+		Name:              span.OperationName,
+		Kind:              getSpanKind(span.Tags),
+		StartTimeUnixNano: startTime,
+		EndTimeUnixNano:   startTime + uint64(span.Duration),
+		Attributes:        attrs,
+		Status:            otlp.SpanStatusFromAttributes(attrs),
+	}
+
+	parentID := span.ParentSpanID()
+	if parentID != 0 {
+		otlpSpan.ParentSpanId = otlp.SpanIDBytes(uint64(parentID))
+	}
+	for _, ref := range span.References {
+		if parentID != 0 && ref.SpanID == parentID && ref.TraceID == span.TraceID && ref.RefType == model.ChildOf {
+			continue
+		}
+		otlpSpan.Links = append(otlpSpan.Links, &trace.Span_Link{
+			TraceId:    otlp.TraceIDBytes(ref.TraceID.High, ref.TraceID.Low),
+			SpanId:     otlp.SpanIDBytes(uint64(ref.SpanID)),
+			Attributes: []*common.KeyValue{otlp.NewAttribute("jaeger.ref_type", strings.ToLower(ref.RefType.String()))},
+		})
+	}
+	for _, log := range span.Logs {
+		otlpSpan.Events = append(otlpSpan.Events, translateLog(log))
+	}
+	return otlpSpan
+}
+
+// translateLog returns the span event for a log, named after its event field
+func translateLog(log model.Log) *trace.Span_Event {
+	event := &trace.Span_Event{
+		TimeUnixNano: uint64(log.Timestamp.UnixNano()),
+		Name:         "log",
+	}
+	for i := range log.Fields {
+		field := &log.Fields[i]
+		if field.Key == "event" && field.VType == model.StringType && field.VStr != "" {
+			event.Name = field.VStr
+			continue
+		}
+		event.Attributes = appendTags(event.Attributes, log.Fields[i:i+1])
+	}
+	return event
+}
+
+func appendTags(attrs []*common.KeyValue, tags []model.KeyValue) []*common.KeyValue {
+	for i := range tags {
+		tag := &tags[i]
+		if tag.Key == "" {
+			continue
+		}
+		switch tag.VType {
+		case model.StringType:
+			attrs = append(attrs, otlp.NewAttribute(tag.Key, tag.VStr))
+		case model.BoolType:
+			attrs = append(attrs, otlp.NewAttribute(tag.Key, tag.VBool))
+		case model.Int64Type:
+			attrs = append(attrs, otlp.NewAttribute(tag.Key, tag.VInt64))
+		case model.Float64Type:
+			attrs = append(attrs, otlp.NewAttribute(tag.Key, tag.VFloat64))
+		case model.BinaryType:
+			attrs = append(attrs, otlp.NewAttribute(tag.Key, tag.AsString()))
+		}
+	}
+	return attrs
+}
+
+// getSpanKind returns the kind from the span.kind tag, Jaeger clients only set it on RPC spans
+func getSpanKind(tags model.KeyValues) trace.Span_SpanKind {
+	kind, _ := tags.FindByKey("span.kind")
+	switch kind.AsString() {
+	case "client":
+		return trace.Span_SPAN_KIND_CLIENT
+	case "server":
+		return trace.Span_SPAN_KIND_SERVER
+	case "producer":
+		return trace.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return trace.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return trace.Span_SPAN_KIND_INTERNAL
+	default:
+		return trace.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+// getSampleRate derives the sample rate from the sampler.type and sampler.param tags set by Jaeger clients
+// Only probabilistic samplers describe a rate, keeping 1 in 1/param traces. A const sampler that keeps
+// everything has a rate of 1, other samplers leave it unset
+func getSampleRate(tags model.KeyValues) int64 {
+	samplerType, _ := tags.FindByKey("sampler.type")
+	samplerParam, _ := tags.FindByKey("sampler.param")
+	var param float64
+	switch samplerParam.VType {
+	case model.Float64Type:
+		param = samplerParam.VFloat64
+	case model.Int64Type:
+		param = float64(samplerParam.VInt64)
+	case model.BoolType:
+		if samplerParam.VBool {
+			param = 1
+		}
+	}
+
+	switch samplerType.AsString() {
+	case "probabilistic":
+		if param <= 0 || param > 1 {
+			return 0
+		}
+		rate := math.Round(1 / param)
+		if rate > math.MaxInt32 {
+			return math.MaxInt32
+		}
+		return int64(rate)
+	case "const":
+		if param == 1 {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+package jaeger
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/honeycombio/husky/otlp"
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/api_v2"
+	jaegerthrift "github.com/jaegertracing/jaeger/thrift-gen/jaeger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var testStartTime = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func stringTag(key string, value string) *jaegerthrift.Tag {
+	return &jaegerthrift.Tag{Key: key, VType: jaegerthrift.TagType_STRING, VStr: &value}
+}
+
+func newThriftTestBatch() *jaegerthrift.Batch {
+	startMicros := testStartTime.UnixNano() / int64(time.Microsecond)
+	samplerParam := 0.25
+	isError := true
+	return &jaegerthrift.Batch{
+		Process: &jaegerthrift.Process{
+			ServiceName: "frontend",
+			Tags:        []*jaegerthrift.Tag{stringTag("hostname", "host-1")},
+		},
+		Spans: []*jaegerthrift.Span{
+			{
+				TraceIdLow:    0x463ac35c9f6413ad,
+				SpanId:        0x72485a3953bb6124,
+				OperationName: "get /api",
+				StartTime:     startMicros,
+				Duration:      1500,
+				Tags: []*jaegerthrift.Tag{
+					stringTag("span.kind", "server"),
+					stringTag("sampler.type", "probabilistic"),
+					{Key: "sampler.param", VType: jaegerthrift.TagType_DOUBLE, VDouble: &samplerParam},
+					{Key: "error", VType: jaegerthrift.TagType_BOOL, VBool: &isError},
+				},
+				Logs: []*jaegerthrift.Log{{
+					Timestamp: startMicros + 500,
+					Fields:    []*jaegerthrift.Tag{stringTag("event", "retry"), stringTag("attempt", "2")},
+				}},
+			},
+			{
+				TraceIdLow:    0x463ac35c9f6413ad,
+				TraceIdHigh:   0x5af7183fb1d4cf5f,
+				SpanId:        0x0102030405060708,
+				ParentSpanId:  0x72485a3953bb6124,
+				OperationName: "query",
+				StartTime:     startMicros + 100,
+				Duration:      800,
+				References: []*jaegerthrift.SpanRef{{
+					RefType:    jaegerthrift.SpanRefType_FOLLOWS_FROM,
+					TraceIdLow: 0x1111,
+					SpanId:     0x2222,
+				}},
+			},
+		},
+	}
+}
+
+func serializeThrift(t *testing.T, batch *jaegerthrift.Batch, compact bool) []byte {
+	transport := thrift.NewTMemoryBuffer()
+	var protocol thrift.TProtocol = thrift.NewTBinaryProtocolConf(transport, nil)
+	if compact {
+		protocol = thrift.NewTCompactProtocolConf(transport, nil)
+	}
+	require.NoError(t, batch.Write(context.Background(), protocol))
+	require.NoError(t, protocol.Flush(context.Background()))
+	return transport.Bytes()
+}
+
+func TestTranslateThriftBatch(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		data := serializeThrift(t, newThriftTestBatch(), compact)
+		translate := TranslateThriftBatch
+		if compact {
+			translate = TranslateThriftCompactBatch
+		}
+		result, err := translate(data, otlp.RequestInfo{Dataset: "dataset"})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(result.Batches))
+		assert.Equal(t, "dataset", result.Batches[0].Dataset)
+
+		events := result.Batches[0].Events
+		require.Equal(t, 2, len(events))
+
+		server := events[0]
+		assert.Equal(t, testStartTime, server.Timestamp)
+		assert.Equal(t, int32(4), server.SampleRate)
+		assert.Equal(t, "463ac35c9f6413ad", server.Attributes["traceTraceID"])
+		assert.Equal(t, "72485a3953bb6124", server.Attributes["traceSpanID"])
+		assert.Equal(t, "server", server.Attributes["spanKind"])
+		assert.Equal(t, 1.5, server.Attributes["durationMs"])
+		assert.Equal(t, trace.Status_STATUS_CODE_ERROR, server.Attributes["statusCode"])
+		assert.Equal(t, true, server.Attributes["error"])
+		assert.Equal(t, 1, server.Attributes["spanNumEvents"])
+		assert.Equal(t, 0, server.Attributes["spanNumLinks"])
+		assert.Equal(t, map[string]interface{}{"service.name": "frontend", "hostname": "host-1"}, server.Attributes["resourceAttributes"])
+		spanAttrs := server.Attributes["spanAttributes"].(map[string]interface{})
+		assert.Equal(t, "probabilistic", spanAttrs["sampler.type"])
+		_, ok := spanAttrs["sampleRate"]
+		assert.False(t, ok)
+		assert.Equal(t, map[string]interface{}{"attempt": "2"}, server.Attributes["eventAttributes"])
+		_, ok = server.Attributes["traceParentID"]
+		assert.False(t, ok)
+
+		client := events[1]
+		assert.Equal(t, "5af7183fb1d4cf5f463ac35c9f6413ad", client.Attributes["traceTraceID"])
+		assert.Equal(t, "72485a3953bb6124", client.Attributes["traceParentID"])
+		assert.Equal(t, "unspecified", client.Attributes["spanKind"])
+		assert.Equal(t, false, client.Attributes["error"])
+		assert.Equal(t, 1, client.Attributes["spanNumLinks"])
+		assert.Equal(t, int32(0), client.SampleRate)
+	}
+}
+
+func TestTranslateProtoBatch(t *testing.T) {
+	request := api_v2.PostSpansRequest{Batch: model.Batch{
+		Process: &model.Process{ServiceName: "frontend"},
+		Spans: []*model.Span{
+			{
+				TraceID:       model.NewTraceID(0, 0x463ac35c9f6413ad),
+				SpanID:        model.NewSpanID(1),
+				OperationName: "root",
+				StartTime:     testStartTime,
+				Duration:      time.Millisecond,
+				Tags:          []model.KeyValue{model.String("otel.status_code", "OK"), model.Int64("http.status_code", 200)},
+			},
+			{
+				TraceID:       model.NewTraceID(0, 0x463ac35c9f6413ad),
+				SpanID:        model.NewSpanID(2),
+				OperationName: "child",
+				References:    []model.SpanRef{model.NewChildOfRef(model.NewTraceID(0, 0x463ac35c9f6413ad), model.NewSpanID(1))},
+				StartTime:     testStartTime,
+				Process:       &model.Process{ServiceName: "backend", Tags: []model.KeyValue{model.String("ip", "10.0.0.1")}},
+				Tags:          []model.KeyValue{model.String("sampler.type", "const"), model.Bool("sampler.param", true)},
+			},
+		},
+	}}
+	data, err := request.Marshal()
+	require.NoError(t, err)
+
+	result, err := TranslateProtoBatch(data, otlp.RequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "frontend", result.Batches[0].Dataset)
+	assert.Equal(t, "backend", result.Batches[1].Dataset)
+
+	root := result.Batches[0].Events[0]
+	assert.Equal(t, trace.Status_STATUS_CODE_OK, root.Attributes["statusCode"])
+	assert.Equal(t, int64(200), root.Attributes["spanAttributes"].(map[string]interface{})["http.status_code"])
+
+	child := result.Batches[1].Events[0]
+	assert.Equal(t, "0000000000000001", child.Attributes["traceParentID"])
+	assert.Equal(t, 0, child.Attributes["spanNumLinks"])
+	assert.Equal(t, int32(1), child.SampleRate)
+	assert.Equal(t, map[string]interface{}{"service.name": "backend", "ip": "10.0.0.1"}, child.Attributes["resourceAttributes"])
+}
+
+func TestTranslateTraceReqFromReader(t *testing.T) {
+	data := serializeThrift(t, newThriftTestBatch(), false)
+	result, err := TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{ContentType: "application/x-thrift"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(result.Batches))
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader([]byte{0xff, 0x01})), otlp.RequestInfo{ContentType: "application/x-thrift"})
+	assert.Equal(t, otlp.ErrFailedParseBody, err)
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{ContentType: "application/json"})
+	assert.Equal(t, otlp.ErrInvalidContentType, err)
+}
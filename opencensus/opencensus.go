@@ -0,0 +1,212 @@
+// Package opencensus translates OpenCensus agent trace requests into the same structure produced by the otlp package
+package opencensus
+
+import (
+	"io"
+	"strings"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/honeycombio/husky/otlp"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TranslateTraceReqFromReader translates a serialised ExportTraceServiceRequest into Opsramp-friendly structure
+func TranslateTraceReqFromReader(body io.ReadCloser, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	switch ri.ContentType {
+	case "application/x-protobuf", "application/protobuf":
+	default:
+		return nil, otlp.ErrInvalidContentType
+	}
+
+	data, err := otlp.ReadBody(body, ri.ContentEncoding)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	request := &agenttracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(data, request); err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return TranslateTraceReq(request, ri, opts...)
+}
+
+// TranslateTraceReq translates an ExportTraceServiceRequest, as received by the OpenCensus agent trace service
+// Node and Resource become resource attributes, spans with their own Resource use it in place of
+// the request's Resource
+func TranslateTraceReq(request *agenttracepb.ExportTraceServiceRequest, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	return otlp.TranslateTraceReq(newTraceRequest(request), ri, opts...)
+}
+
+// newTraceRequest converts the request into an OTLP request with a resource per OpenCensus resource,
+// in the order they were first seen
+func newTraceRequest(request *agenttracepb.ExportTraceServiceRequest) *collectorTrace.ExportTraceServiceRequest {
+	otlpRequest := &collectorTrace.ExportTraceServiceRequest{}
+	librarySpans := make(map[*resourcepb.Resource]*trace.ScopeSpans)
+	for _, span := range request.Spans {
+		if span == nil {
+			continue
+		}
+		res := span.Resource
+		if res == nil {
+			res = request.Resource
+		}
+		library, ok := librarySpans[res]
+		if !ok {
+			library = &trace.ScopeSpans{}
+			librarySpans[res] = library
+			otlpRequest.ResourceSpans = append(otlpRequest.ResourceSpans, &trace.ResourceSpans{
+				Resource:   &resource.Resource{Attributes: getResourceAttributes(request.Node, res)},
+				ScopeSpans: []*trace.ScopeSpans{library},
+			})
+		}
+		library.Spans = append(library.Spans, translateSpan(span))
+	}
+	return otlpRequest
+}
+
+// getResourceAttributes maps the node identity and the resource onto OpenTelemetry resource attributes
+func getResourceAttributes(node *commonpb.Node, res *resourcepb.Resource) []*common.KeyValue {
+	var attrs []*common.KeyValue
+	if node != nil {
+		for k, v := range node.Attributes {
+			attrs = append(attrs, otlp.NewAttribute(k, v))
+		}
+		if identifier := node.Identifier; identifier != nil {
+			if identifier.HostName != "" {
+				attrs = append(attrs, otlp.NewAttribute("host.name", identifier.HostName))
+			}
+			if identifier.Pid != 0 {
+				attrs = append(attrs, otlp.NewAttribute("process.pid", int64(identifier.Pid)))
+			}
+		}
+		if library := node.LibraryInfo; library != nil {
+			if library.Language != commonpb.LibraryInfo_LANGUAGE_UNSPECIFIED {
+				attrs = append(attrs, otlp.NewAttribute("telemetry.sdk.language", strings.ToLower(library.Language.String())))
+			}
+			if library.CoreLibraryVersion != "" {
+				attrs = append(attrs, otlp.NewAttribute("telemetry.sdk.version", library.CoreLibraryVersion))
+			}
+			if library.ExporterVersion != "" {
+				attrs = append(attrs, otlp.NewAttribute("opencensus.exporterversion", library.ExporterVersion))
+			}
+		}
+	}
+	if res != nil {
+		for k, v := range res.Labels {
+			attrs = append(attrs, otlp.NewAttribute(k, v))
+		}
+		if res.Type != "" {
+			attrs = append(attrs, otlp.NewAttribute("opencensus.resourcetype", res.Type))
+		}
+	}
+	// the service name takes precedence over a service.name node attribute or label
+	if node != nil && node.ServiceInfo != nil && node.ServiceInfo.Name != "" {
+		attrs = append(attrs, otlp.NewAttribute("service.name", node.ServiceInfo.Name))
+	}
+	return attrs
+}
+
+// translateSpan converts the span into an OTLP span, time events become span events
+func translateSpan(span *tracepb.Span) *trace.Span {
+	otlpSpan := &trace.Span{
+		TraceId:           span.TraceId,
+		SpanId:            span.SpanId,
+		ParentSpanId:      span.ParentSpanId,
+		Name:              span.Name.GetValue(),
+		Kind:              getSpanKind(span.Kind),
+		StartTimeUnixNano: getTime(span.StartTime),
+		EndTimeUnixNano:   getTime(span.EndTime),
+		Attributes:        getAttributes(span.Attributes),
+	}
+	if span.Status != nil {
+		otlpSpan.Status = &trace.Status{
+			Code:    otlp.StatusCodeFromLegacy(span.Status.Code),
+			Message: span.Status.Message,
+		}
+	}
+	for _, timeEvent := range span.TimeEvents.GetTimeEvent() {
+		if timeEvent != nil {
+			otlpSpan.Events = append(otlpSpan.Events, getTimeEvent(timeEvent))
+		}
+	}
+	for _, link := range span.Links.GetLink() {
+		if link == nil {
+			continue
+		}
+		attrs := getAttributes(link.Attributes)
+		if link.Type != tracepb.Span_Link_TYPE_UNSPECIFIED {
+			attrs = append(attrs, otlp.NewAttribute("opencensus.link.type", strings.ToLower(link.Type.String())))
+		}
+		otlpSpan.Links = append(otlpSpan.Links, &trace.Span_Link{
+			TraceId:    link.TraceId,
+			SpanId:     link.SpanId,
+			Attributes: attrs,
+		})
+	}
+	return otlpSpan
+}
+
+// getTimeEvent returns the span event for an annotation or message event
+// Annotations are named after their description, message events are named message
+func getTimeEvent(timeEvent *tracepb.Span_TimeEvent) *trace.Span_Event {
+	event := &trace.Span_Event{TimeUnixNano: getTime(timeEvent.Time)}
+	if annotation := timeEvent.GetAnnotation(); annotation != nil {
+		event.Name = annotation.Description.GetValue()
+		event.Attributes = getAttributes(annotation.Attributes)
+	} else if message := timeEvent.GetMessageEvent(); message != nil {
+		event.Name = "message"
+		event.Attributes = append(event.Attributes,
+			otlp.NewAttribute("message.type", strings.ToLower(message.Type.String())),
+			otlp.NewAttribute("message.id", int64(message.Id)))
+		if message.UncompressedSize != 0 {
+			event.Attributes = append(event.Attributes, otlp.NewAttribute("message.uncompressed_size", int64(message.UncompressedSize)))
+		}
+		if message.CompressedSize != 0 {
+			event.Attributes = append(event.Attributes, otlp.NewAttribute("message.compressed_size", int64(message.CompressedSize)))
+		}
+	}
+	return event
+}
+
+func getSpanKind(kind tracepb.Span_SpanKind) trace.Span_SpanKind {
+	switch kind {
+	case tracepb.Span_SERVER:
+		return trace.Span_SPAN_KIND_SERVER
+	case tracepb.Span_CLIENT:
+		return trace.Span_SPAN_KIND_CLIENT
+	default:
+		return trace.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func getAttributes(attributes *tracepb.Span_Attributes) []*common.KeyValue {
+	var attrs []*common.KeyValue
+	for k, v := range attributes.GetAttributeMap() {
+		switch value := v.GetValue().(type) {
+		case *tracepb.AttributeValue_StringValue:
+			attrs = append(attrs, otlp.NewAttribute(k, value.StringValue.GetValue()))
+		case *tracepb.AttributeValue_IntValue:
+			attrs = append(attrs, otlp.NewAttribute(k, value.IntValue))
+		case *tracepb.AttributeValue_BoolValue:
+			attrs = append(attrs, otlp.NewAttribute(k, value.BoolValue))
+		case *tracepb.AttributeValue_DoubleValue:
+			attrs = append(attrs, otlp.NewAttribute(k, value.DoubleValue))
+		}
+	}
+	return attrs
+}
+
+// getTime converts a timestamp, a missing timestamp is the Unix epoch
+func getTime(ts *timestamppb.Timestamp) uint64 {
+	if ts == nil {
+		return 0
+	}
+	return uint64(ts.AsTime().UnixNano())
+}
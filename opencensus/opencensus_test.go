@@ -0,0 +1,183 @@
+package opencensus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/honeycombio/husky/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var testStartTime = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func truncatable(s string) *tracepb.TruncatableString {
+	return &tracepb.TruncatableString{Value: s}
+}
+
+func newTestRequest() *agenttracepb.ExportTraceServiceRequest {
+	return &agenttracepb.ExportTraceServiceRequest{
+		Node: &commonpb.Node{
+			Identifier:  &commonpb.ProcessIdentifier{HostName: "host-1", Pid: 42},
+			LibraryInfo: &commonpb.LibraryInfo{Language: commonpb.LibraryInfo_GO_LANG, CoreLibraryVersion: "0.23.0", ExporterVersion: "0.1.0"},
+			ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"},
+			Attributes:  map[string]string{"deployment.environment": "prod"},
+		},
+		Resource: &resourcepb.Resource{Type: "k8s", Labels: map[string]string{"k8s.pod.name": "checkout-1"}},
+		Spans: []*tracepb.Span{
+			{
+				TraceId:   []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+				SpanId:    []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				Name:      truncatable("GET /cart"),
+				Kind:      tracepb.Span_SERVER,
+				StartTime: timestamppb.New(testStartTime),
+				EndTime:   timestamppb.New(testStartTime.Add(5 * time.Millisecond)),
+				Attributes: &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+					"http.method":      {Value: &tracepb.AttributeValue_StringValue{StringValue: truncatable("GET")}},
+					"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 500}},
+					"retry":            {Value: &tracepb.AttributeValue_BoolValue{BoolValue: true}},
+					"ratio":            {Value: &tracepb.AttributeValue_DoubleValue{DoubleValue: 0.5}},
+				}},
+				TimeEvents: &tracepb.Span_TimeEvents{TimeEvent: []*tracepb.Span_TimeEvent{
+					{
+						Time: timestamppb.New(testStartTime.Add(time.Millisecond)),
+						Value: &tracepb.Span_TimeEvent_Annotation_{Annotation: &tracepb.Span_TimeEvent_Annotation{
+							Description: truncatable("cache miss"),
+							Attributes: &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+								"key": {Value: &tracepb.AttributeValue_StringValue{StringValue: truncatable("cart-1")}},
+							}},
+						}},
+					},
+					{
+						Time: timestamppb.New(testStartTime.Add(2 * time.Millisecond)),
+						Value: &tracepb.Span_TimeEvent_MessageEvent_{MessageEvent: &tracepb.Span_TimeEvent_MessageEvent{
+							Type: tracepb.Span_TimeEvent_MessageEvent_SENT, Id: 1, UncompressedSize: 100,
+						}},
+					},
+				}},
+				Links: &tracepb.Span_Links{Link: []*tracepb.Span_Link{
+					{TraceId: []byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}, SpanId: []byte{8, 7, 6, 5, 4, 3, 2, 1}, Type: tracepb.Span_Link_PARENT_LINKED_SPAN},
+				}},
+				Status: &tracepb.Status{Code: 13, Message: "internal error"},
+			},
+			{
+				TraceId:      []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+				SpanId:       []byte{2, 2, 2, 2, 2, 2, 2, 2},
+				ParentSpanId: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				Name:         truncatable("SELECT"),
+				Kind:         tracepb.Span_CLIENT,
+				StartTime:    timestamppb.New(testStartTime),
+				EndTime:      timestamppb.New(testStartTime.Add(time.Millisecond)),
+				Status:       &tracepb.Status{Code: 0},
+				Resource:     &resourcepb.Resource{Type: "db"},
+			},
+		},
+	}
+}
+
+func TestTranslateTraceReq(t *testing.T) {
+	result, err := TranslateTraceReq(newTestRequest(), otlp.RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "dataset", result.Batches[0].Dataset)
+	assert.Equal(t, "dataset", result.Batches[1].Dataset)
+
+	events := result.Batches[0].Events
+	require.Equal(t, 1, len(events))
+
+	span := events[0]
+	assert.Equal(t, testStartTime, span.Timestamp)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", span.Attributes["traceTraceID"])
+	assert.Equal(t, "0102030405060708", span.Attributes["traceSpanID"])
+	assert.Equal(t, "server", span.Attributes["spanKind"])
+	assert.Equal(t, "GET /cart", span.Attributes["spanName"])
+	assert.Equal(t, 5.0, span.Attributes["durationMs"])
+	assert.Equal(t, trace.Status_STATUS_CODE_ERROR, span.Attributes["statusCode"])
+	assert.Equal(t, "internal error", span.Attributes["statusMessage"])
+	assert.Equal(t, true, span.Attributes["error"])
+	assert.Equal(t, 2, span.Attributes["spanNumEvents"])
+	assert.Equal(t, 1, span.Attributes["spanNumLinks"])
+	assert.Equal(t, map[string]interface{}{
+		"service.name":               "checkout",
+		"host.name":                  "host-1",
+		"process.pid":                int64(42),
+		"telemetry.sdk.language":     "go_lang",
+		"telemetry.sdk.version":      "0.23.0",
+		"opencensus.exporterversion": "0.1.0",
+		"deployment.environment":     "prod",
+		"opencensus.resourcetype":    "k8s",
+		"k8s.pod.name":               "checkout-1",
+	}, span.Attributes["resourceAttributes"])
+	assert.Equal(t, map[string]interface{}{
+		"http.method":      "GET",
+		"http.status_code": int64(500),
+		"retry":            true,
+		"ratio":            0.5,
+	}, span.Attributes["spanAttributes"])
+	assert.Equal(t, map[string]interface{}{
+		"key":                       "cart-1",
+		"message.type":              "sent",
+		"message.id":                int64(1),
+		"message.uncompressed_size": int64(100),
+	}, span.Attributes["eventAttributes"])
+	_, ok := span.Attributes["traceParentID"]
+	assert.False(t, ok)
+
+	child := result.Batches[1].Events[0]
+	assert.Equal(t, "0102030405060708", child.Attributes["traceParentID"])
+	assert.Equal(t, "client", child.Attributes["spanKind"])
+	assert.Equal(t, trace.Status_STATUS_CODE_UNSET, child.Attributes["statusCode"])
+	assert.Equal(t, false, child.Attributes["error"])
+	childResource := child.Attributes["resourceAttributes"].(map[string]interface{})
+	assert.Equal(t, "db", childResource["opencensus.resourcetype"])
+	assert.Equal(t, "checkout", childResource["service.name"])
+	_, ok = childResource["k8s.pod.name"]
+	assert.False(t, ok)
+}
+
+func TestTranslateTraceReqWithoutRequestDataset(t *testing.T) {
+	result, err := TranslateTraceReq(newTestRequest(), otlp.RequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "checkout", result.Batches[0].Dataset)
+	assert.Equal(t, "checkout", result.Batches[1].Dataset)
+}
+
+func TestTranslateTraceReqWithoutNode(t *testing.T) {
+	request := &agenttracepb.ExportTraceServiceRequest{
+		Spans: []*tracepb.Span{{TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8}, SpanId: []byte{1, 2, 3, 4, 5, 6, 7, 8}}},
+	}
+	result, err := TranslateTraceReq(request, otlp.RequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "unknown_service", result.Batches[0].Dataset)
+
+	span := result.Batches[0].Events[0]
+	assert.Equal(t, "unspecified", span.Attributes["spanKind"])
+	assert.Equal(t, trace.Status_STATUS_CODE_UNSET, span.Attributes["statusCode"])
+	assert.Equal(t, map[string]interface{}{}, span.Attributes["resourceAttributes"])
+}
+
+func TestTranslateTraceReqFromReader(t *testing.T) {
+	data, err := proto.Marshal(newTestRequest())
+	require.NoError(t, err)
+
+	result, err := TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{ContentType: "application/x-protobuf"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(result.Batches))
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader([]byte{0xff})), otlp.RequestInfo{ContentType: "application/protobuf"})
+	assert.Equal(t, otlp.ErrFailedParseBody, err)
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(data)), otlp.RequestInfo{ContentType: "application/json"})
+	assert.Equal(t, otlp.ErrInvalidContentType, err)
+}
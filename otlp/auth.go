@@ -0,0 +1,505 @@
+package otlp
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bearerScheme           = "bearer "
+	defaultJWTCacheTTL     = time.Minute
+	maxAuthCacheEntries    = 10000
+	maxAuthCacheRejections = 1000
+	es256SignatureLength   = 64
+)
+
+// Principal is the identity resolved from the credentials of a request
+// TenantId is empty when the authenticator has no notion of tenants
+type Principal struct {
+	Subject  string
+	TenantId string
+	Claims   map[string]interface{}
+}
+
+// Authenticator verifies the credentials carried in RequestInfo.ApiToken
+// Implementations return ErrMissingCredentials when no credentials were sent and
+// ErrInvalidCredentials when the credentials could not be verified
+type Authenticator interface {
+	Authenticate(ctx context.Context, ri RequestInfo) (*Principal, error)
+}
+
+// StaticKeyAuthenticator accepts the raw authorization value if it matches one of a fixed set of keys
+type StaticKeyAuthenticator struct {
+	keys map[string]string
+}
+
+// NewStaticKeyAuthenticator creates an authenticator from a map of API key to tenant ID
+func NewStaticKeyAuthenticator(keys map[string]string) *StaticKeyAuthenticator {
+	return &StaticKeyAuthenticator{keys: keys}
+}
+
+func (a *StaticKeyAuthenticator) Authenticate(_ context.Context, ri RequestInfo) (*Principal, error) {
+	if ri.ApiToken == "" {
+		return nil, ErrMissingCredentials
+	}
+	return a.lookup(ri.ApiToken)
+}
+
+func (a *StaticKeyAuthenticator) lookup(token string) (*Principal, error) {
+	// compare against every key so the time taken does not reveal which key was closest
+	var principal *Principal
+	for key, tenant := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			principal = &Principal{TenantId: tenant}
+		}
+	}
+	if principal == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+// BearerTokenAuthenticator accepts "Bearer <token>" authorization values where token is one of a fixed set of tokens
+type BearerTokenAuthenticator struct {
+	static StaticKeyAuthenticator
+}
+
+// NewBearerTokenAuthenticator creates an authenticator from a map of bearer token to tenant ID
+func NewBearerTokenAuthenticator(tokens map[string]string) *BearerTokenAuthenticator {
+	return &BearerTokenAuthenticator{static: StaticKeyAuthenticator{keys: tokens}}
+}
+
+func (a *BearerTokenAuthenticator) Authenticate(_ context.Context, ri RequestInfo) (*Principal, error) {
+	if ri.ApiToken == "" {
+		return nil, ErrMissingCredentials
+	}
+	token, ok := parseBearerToken(ri.ApiToken)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return a.static.lookup(token)
+}
+
+// parseBearerToken extracts the token from an authorization value using the bearer scheme
+func parseBearerToken(authorization string) (string, bool) {
+	if len(authorization) <= len(bearerScheme) || !strings.EqualFold(authorization[:len(bearerScheme)], bearerScheme) {
+		return "", false
+	}
+	token := strings.TrimSpace(authorization[len(bearerScheme):])
+	return token, token != ""
+}
+
+// JWTConfig configures validation of JWT bearer tokens
+// JWKSFile is the path to a local JSON Web Key Set holding the RSA and P-256 EC verification keys
+// Audience lists the accepted audiences, the check is skipped when empty
+// Issuer is the required issuer, the check is skipped when empty
+// TenantClaim names the claim that supplies the tenant ID, tokens without it are rejected
+// Leeway is the clock skew allowed when checking exp and nbf
+// CacheTTL is how long verification results are cached, zero uses a default and a negative value disables caching
+type JWTConfig struct {
+	JWKSFile    string
+	Audience    []string
+	Issuer      string
+	TenantClaim string
+	Leeway      time.Duration
+	CacheTTL    time.Duration
+}
+
+// JWTAuthenticator validates RS256 and ES256 signed JWT bearer tokens against a local JWKS
+type JWTAuthenticator struct {
+	config JWTConfig
+	keys   []jsonWebKey
+	cache  *authCache
+	now    func() time.Time
+}
+
+// NewJWTAuthenticator loads the configured JWKS file and returns an authenticator using its keys
+func NewJWTAuthenticator(config JWTConfig) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(config.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	ttl := config.CacheTTL
+	if ttl == 0 {
+		ttl = defaultJWTCacheTTL
+	}
+	a := &JWTAuthenticator{
+		config: config,
+		keys:   keys,
+		now:    time.Now,
+	}
+	if ttl > 0 {
+		a.cache = newAuthCache(ttl)
+	}
+	return a, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(_ context.Context, ri RequestInfo) (*Principal, error) {
+	if ri.ApiToken == "" {
+		return nil, ErrMissingCredentials
+	}
+	token, ok := parseBearerToken(ri.ApiToken)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := a.now()
+	if a.cache != nil {
+		if principal, err, found := a.cache.get(token, now); found {
+			return principal.copy(), err
+		}
+	}
+
+	principal, expiry, err := a.verify(token, now)
+	if err != nil {
+		err = ErrInvalidCredentials
+	}
+	if a.cache != nil {
+		a.cache.set(token, principal, err, now, expiry)
+	}
+	return principal.copy(), err
+}
+
+// copy returns a deep copy of the principal so callers cannot modify a cached result
+func (p *Principal) copy() *Principal {
+	if p == nil {
+		return nil
+	}
+	copied := *p
+	if p.Claims != nil {
+		copied.Claims = copyClaim(p.Claims).(map[string]interface{})
+	}
+	return &copied
+}
+
+func copyClaim(claim interface{}) interface{} {
+	switch v := claim.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			copied[key] = copyClaim(val)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, val := range v {
+			copied[i] = copyClaim(val)
+		}
+		return copied
+	}
+	return claim
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the signature and registered claims of a compact serialised JWT
+// The returned time is when the result stops holding, the token expiry for valid tokens and
+// the time a token becomes valid when it is rejected for its nbf claim, or zero if there is none
+func (a *JWTAuthenticator) verify(token string, now time.Time) (*Principal, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, time.Time{}, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, time.Time{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !a.verifySignature(header, digest[:], signature) {
+		return nil, time.Time{}, errors.New("invalid signature")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var expiry time.Time
+	if exp, ok := numericClaim(claims, "exp"); ok {
+		expiry = exp
+		if !now.Before(exp.Add(a.config.Leeway)) {
+			return nil, time.Time{}, errors.New("token expired")
+		}
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(a.config.Leeway).Before(nbf) {
+		// the token becomes valid later, so the rejection is only cached until then
+		return nil, nbf.Add(-a.config.Leeway), errors.New("token not yet valid")
+	}
+	if a.config.Issuer != "" && claims["iss"] != a.config.Issuer {
+		return nil, time.Time{}, errors.New("unexpected issuer")
+	}
+	if len(a.config.Audience) > 0 && !audienceMatches(claims["aud"], a.config.Audience) {
+		return nil, time.Time{}, errors.New("unexpected audience")
+	}
+
+	principal := &Principal{Claims: claims}
+	if sub, ok := claims["sub"].(string); ok {
+		principal.Subject = sub
+	}
+	if a.config.TenantClaim != "" {
+		tenant, ok := claims[a.config.TenantClaim].(string)
+		if !ok || tenant == "" {
+			return nil, time.Time{}, errors.New("missing tenant claim")
+		}
+		principal.TenantId = tenant
+	}
+	return principal, expiry, nil
+}
+
+func (a *JWTAuthenticator) verifySignature(header jwtHeader, digest []byte, signature []byte) bool {
+	for _, key := range a.keys {
+		if header.Kid != "" && key.Kid != header.Kid {
+			continue
+		}
+		if key.Alg != "" && key.Alg != header.Alg {
+			continue
+		}
+		switch pub := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			if header.Alg == "RS256" && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if header.Alg == "ES256" && len(signature) == es256SignatureLength {
+				r := new(big.Int).SetBytes(signature[:es256SignatureLength/2])
+				s := new(big.Int).SetBytes(signature[es256SignatureLength/2:])
+				if ecdsa.Verify(pub, digest, r, s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func numericClaim(claims map[string]interface{}, name string) (time.Time, bool) {
+	number, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+func audienceMatches(aud interface{}, accepted []string) bool {
+	var audiences []string
+	switch v := aud.(type) {
+	case string:
+		audiences = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	for _, candidate := range audiences {
+		for _, want := range accepted {
+			if candidate == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	publicKey crypto.PublicKey
+}
+
+// parseJWKS decodes a JSON Web Key Set, keeping the RSA and P-256 EC keys
+func parseJWKS(data []byte) ([]jsonWebKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	var keys []jsonWebKey
+	for _, key := range set.Keys {
+		switch key.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA modulus for key %q: %w", key.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA exponent for key %q: %w", key.Kid, err)
+			}
+			key.publicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if key.Crv != "P-256" {
+				continue
+			}
+			x, err := base64.RawURLEncoding.DecodeString(key.X)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC x coordinate for key %q: %w", key.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(key.Y)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC y coordinate for key %q: %w", key.Kid, err)
+			}
+			key.publicKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		default:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable RSA or P-256 keys")
+	}
+	return keys, nil
+}
+
+type authCacheEntry struct {
+	token     string
+	principal *Principal
+	err       error
+	expires   time.Time
+}
+
+// authCache remembers authentication results for a token until the TTL or the given expiry passes
+// Rejections are kept apart from accepted tokens and bounded lower, so a flood of made up tokens
+// only evicts other rejections and never the tokens of legitimate clients
+type authCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	accepted *authLRU
+	rejected *authLRU
+}
+
+func newAuthCache(ttl time.Duration) *authCache {
+	return &authCache{
+		ttl:      ttl,
+		accepted: newAuthLRU(maxAuthCacheEntries),
+		rejected: newAuthLRU(maxAuthCacheRejections),
+	}
+}
+
+func (c *authCache) get(token string, now time.Time) (*Principal, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entries := range []*authLRU{c.accepted, c.rejected} {
+		if entry, ok := entries.get(token, now); ok {
+			return entry.principal, entry.err, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (c *authCache) set(token string, principal *Principal, err error, now time.Time, tokenExpiry time.Time) {
+	expires := now.Add(c.ttl)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expires) {
+		expires = tokenExpiry
+	}
+	entry := &authCacheEntry{token: token, principal: principal, err: err, expires: expires}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.accepted.remove(token)
+		c.rejected.add(entry)
+	} else {
+		c.rejected.remove(token)
+		c.accepted.add(entry)
+	}
+}
+
+// authLRU holds at most max entries, evicting the least recently used one when full
+type authLRU struct {
+	max      int
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newAuthLRU(max int) *authLRU {
+	return &authLRU{max: max, order: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (l *authLRU) get(token string, now time.Time) (*authCacheEntry, bool) {
+	element, ok := l.elements[token]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*authCacheEntry)
+	if !now.Before(entry.expires) {
+		l.order.Remove(element)
+		delete(l.elements, token)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return entry, true
+}
+
+func (l *authLRU) add(entry *authCacheEntry) {
+	if element, ok := l.elements[entry.token]; ok {
+		element.Value = entry
+		l.order.MoveToFront(element)
+		return
+	}
+	l.elements[entry.token] = l.order.PushFront(entry)
+	if l.order.Len() > l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.elements, oldest.Value.(*authCacheEntry).token)
+	}
+}
+
+func (l *authLRU) remove(token string) {
+	if element, ok := l.elements[token]; ok {
+		l.order.Remove(element)
+		delete(l.elements, token)
+	}
+}
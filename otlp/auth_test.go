@@ -0,0 +1,314 @@
+package otlp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticKeyAuthenticator(t *testing.T) {
+	auth := NewStaticKeyAuthenticator(map[string]string{"key-1": "tenant-1"})
+
+	principal, err := auth.Authenticate(context.Background(), RequestInfo{ApiToken: "key-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "tenant-1", principal.TenantId)
+
+	_, err = auth.Authenticate(context.Background(), RequestInfo{ApiToken: "key-2"})
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = auth.Authenticate(context.Background(), RequestInfo{})
+	assert.Equal(t, ErrMissingCredentials, err)
+}
+
+func TestBearerTokenAuthenticator(t *testing.T) {
+	auth := NewBearerTokenAuthenticator(map[string]string{"token-1": "tenant-1"})
+
+	testCases := []struct {
+		authorization string
+		tenant        string
+		err           error
+	}{
+		{authorization: "Bearer token-1", tenant: "tenant-1"},
+		{authorization: "bearer token-1", tenant: "tenant-1"},
+		{authorization: "token-1", err: ErrInvalidCredentials},
+		{authorization: "Bearer token-2", err: ErrInvalidCredentials},
+		{authorization: "Bearer ", err: ErrInvalidCredentials},
+		{authorization: "", err: ErrMissingCredentials},
+	}
+
+	for _, tc := range testCases {
+		principal, err := auth.Authenticate(context.Background(), RequestInfo{ApiToken: tc.authorization})
+		assert.Equal(t, tc.err, err, tc.authorization)
+		if tc.err == nil {
+			assert.Equal(t, tc.tenant, principal.TenantId)
+		}
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	auth, err := NewJWTAuthenticator(JWTConfig{
+		JWKSFile:    writeJWKS(t, rsaKey, ecKey),
+		Audience:    []string{"husky"},
+		Issuer:      "https://issuer.example.com",
+		TenantClaim: "tenant",
+		CacheTTL:    -1,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":    "user-1",
+			"iss":    "https://issuer.example.com",
+			"aud":    []string{"other", "husky"},
+			"tenant": "tenant-1",
+			"exp":    now.Add(time.Hour).Unix(),
+			"nbf":    now.Add(-time.Minute).Unix(),
+		}
+	}
+	withClaim := func(key string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "rs256", token: signRS256(t, rsaKey, "rsa-key", validClaims())},
+		{name: "es256", token: signES256(t, ecKey, "ec-key", validClaims())},
+		{name: "string audience", token: signRS256(t, rsaKey, "rsa-key", withClaim("aud", "husky"))},
+		{name: "expired", token: signRS256(t, rsaKey, "rsa-key", withClaim("exp", now.Add(-time.Minute).Unix())), err: ErrInvalidCredentials},
+		{name: "not yet valid", token: signRS256(t, rsaKey, "rsa-key", withClaim("nbf", now.Add(time.Hour).Unix())), err: ErrInvalidCredentials},
+		{name: "wrong audience", token: signRS256(t, rsaKey, "rsa-key", withClaim("aud", "other")), err: ErrInvalidCredentials},
+		{name: "wrong issuer", token: signRS256(t, rsaKey, "rsa-key", withClaim("iss", "https://other.example.com")), err: ErrInvalidCredentials},
+		{name: "missing tenant", token: signRS256(t, rsaKey, "rsa-key", withClaim("tenant", nil)), err: ErrInvalidCredentials},
+		{name: "unknown key", token: signRS256(t, otherKey, "rsa-key", validClaims()), err: ErrInvalidCredentials},
+		{name: "key type mismatch", token: signRS256(t, rsaKey, "ec-key", validClaims()), err: ErrInvalidCredentials},
+		{name: "malformed", token: "not-a-jwt", err: ErrInvalidCredentials},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			principal, err := auth.Authenticate(context.Background(), RequestInfo{ApiToken: "Bearer " + tc.token})
+			assert.Equal(t, tc.err, err)
+			if tc.err == nil {
+				assert.Equal(t, "user-1", principal.Subject)
+				assert.Equal(t, "tenant-1", principal.TenantId)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorCachesResults(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	auth, err := NewJWTAuthenticator(JWTConfig{
+		JWKSFile: writeJWKS(t, rsaKey, nil),
+		CacheTTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+	token := signRS256(t, rsaKey, "rsa-key", map[string]interface{}{
+		"sub": "user-1",
+		"exp": now.Add(time.Hour).Unix(),
+	})
+	ri := RequestInfo{ApiToken: "Bearer " + token}
+
+	first, err := auth.Authenticate(context.Background(), ri)
+	require.NoError(t, err)
+
+	// without keys only a cached result can succeed
+	keys := auth.keys
+	auth.keys = nil
+	second, err := auth.Authenticate(context.Background(), ri)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	now = now.Add(2 * time.Minute)
+	_, err = auth.Authenticate(context.Background(), ri)
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// cached entries never outlive the token itself
+	auth.keys = keys
+	now = now.Add(time.Hour)
+	_, err = auth.Authenticate(context.Background(), ri)
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestJWTAuthenticatorReturnsCopies(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	auth, err := NewJWTAuthenticator(JWTConfig{JWKSFile: writeJWKS(t, rsaKey, nil)})
+	require.NoError(t, err)
+	ri := RequestInfo{ApiToken: "Bearer " + signRS256(t, rsaKey, "rsa-key", map[string]interface{}{
+		"sub": "user-1",
+		"aud": []interface{}{"a", "b"},
+	})}
+
+	first, err := auth.Authenticate(context.Background(), ri)
+	require.NoError(t, err)
+	first.Subject = "changed"
+	first.Claims["sub"] = "changed"
+	first.Claims["aud"].([]interface{})[0] = "changed"
+
+	second, err := auth.Authenticate(context.Background(), ri)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", second.Subject)
+	assert.Equal(t, "user-1", second.Claims["sub"])
+	assert.Equal(t, []interface{}{"a", "b"}, second.Claims["aud"])
+}
+
+func TestJWTAuthenticatorDoesNotCacheNotYetValidPastNbf(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	auth, err := NewJWTAuthenticator(JWTConfig{
+		JWKSFile: writeJWKS(t, rsaKey, nil),
+		CacheTTL: time.Minute,
+		Leeway:   5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+	ri := RequestInfo{ApiToken: "Bearer " + signRS256(t, rsaKey, "rsa-key", map[string]interface{}{
+		"sub": "user-1",
+		"nbf": now.Add(10 * time.Second).Unix(),
+	})}
+
+	_, err = auth.Authenticate(context.Background(), ri)
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// accepted as soon as nbf is within the leeway, not when the cached rejection would have expired
+	now = now.Add(6 * time.Second)
+	principal, err := auth.Authenticate(context.Background(), ri)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", principal.Subject)
+}
+
+func TestJWTAuthenticatorRejectsInvalidJWKS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`), 0600))
+
+	_, err := NewJWTAuthenticator(JWTConfig{JWKSFile: path})
+	assert.Error(t, err)
+
+	_, err = NewJWTAuthenticator(JWTConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	var keys []map[string]string
+	if rsaKey != nil {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": "rsa-key",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		})
+	}
+	if ecKey != nil {
+		keys = append(keys, map[string]string{
+			"kty": "EC",
+			"kid": "ec-key",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func signingInput(t *testing.T, alg string, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString(header),
+		base64.RawURLEncoding.EncodeToString(payload),
+	}, ".")
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := signingInput(t, "RS256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := signingInput(t, "ES256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthCacheBoundsRejectionsSeparately(t *testing.T) {
+	now := time.Now()
+	cache := &authCache{ttl: time.Minute, accepted: newAuthLRU(2), rejected: newAuthLRU(1)}
+	cache.set("good-1", &Principal{Subject: "1"}, nil, now, time.Time{})
+	cache.set("good-2", &Principal{Subject: "2"}, nil, now, time.Time{})
+
+	// rejected tokens only evict each other
+	for _, token := range []string{"bad-1", "bad-2", "bad-3"} {
+		cache.set(token, nil, ErrInvalidCredentials, now, time.Time{})
+	}
+	_, _, found := cache.get("bad-1", now)
+	assert.False(t, found)
+	_, err, found := cache.get("bad-3", now)
+	assert.True(t, found)
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// the least recently used accepted token is evicted first
+	_, _, found = cache.get("good-1", now)
+	assert.True(t, found)
+	cache.set("good-3", &Principal{Subject: "3"}, nil, now, time.Time{})
+	_, _, found = cache.get("good-2", now)
+	assert.False(t, found)
+	for _, token := range []string{"good-1", "good-3"} {
+		principal, _, found := cache.get(token, now)
+		assert.True(t, found)
+		assert.NotNil(t, principal)
+	}
+}
@@ -6,14 +6,15 @@ import (
 	common "go.opentelemetry.io/proto/otlp/common/v1"
 	"google.golang.org/grpc/metadata"
 	"net/http"
+	"unicode/utf8"
 )
 
 const (
 	//apiKeyHeader             = "x-opsramp-team"
 	datasetHeader            = "x-opsramp-dataset"
-	//proxyTokenHeader         = "x-opsramp-proxy-token"
-	//proxyVersionHeader       = "x-basenji-version"
-	//userAgentHeader          = "user-agent"
+	proxyTokenHeader         = "x-opsramp-proxy-token"
+	proxyVersionHeader       = "x-basenji-version"
+	userAgentHeader          = "user-agent"
 	contentTypeHeader        = "content-type"
 	//contentEncodingHeader    = "content-encoding"
 	//gRPCAcceptEncodingHeader = "grpc-accept-encoding"
@@ -21,37 +22,44 @@ const (
 	apiTenantId              = "tenantId"
 )
 
+// fieldSizeMax is the longest attribute value kept, longer strings are truncated
+const fieldSizeMax = 64 * 1024
+
 //var legacyApiKeyPattern = regexp.MustCompile("^[0-9a-f]{32}$")
 
 // RequestInfo represents information parsed from either HTTP headers or gRPC metadata
 type RequestInfo struct {
 	//ApiKey       string
 	Dataset      string
-	//ProxyToken   string
-	//ProxyVersion string
+	ProxyToken   string
+	ProxyVersion string
 	//
-	//UserAgent          string
+	UserAgent          string
 	ContentType        string
 	ContentEncoding    string
 	//GRPCAcceptEncoding string
 
 	ApiToken    string
 	ApiTenantId string
+
+	// RemoteAddr is the address of the client, taken from the gRPC peer or the HTTP request
+	RemoteAddr string
+
+	// Extra holds the allowlisted headers from HeaderMapping.Extra keyed by lowercased name
+	Extra map[string]string
 }
 
 // ValidateTracesHeaders validates required headers/metadata for a trace OTLP request
-//func (ri *RequestInfo) ValidateTracesHeaders() error {
-//	//if len(ri.ApiKey) == 0 {
-//	//	return ErrMissingAPIKeyHeader
-//	//}
-//	if  len(ri.Dataset) == 0 {
-//		return ErrMissingDatasetHeader
-//	}
-//	if ri.ContentType != "application/protobuf" && ri.ContentType != "application/x-protobuf" {
-//		return ErrInvalidContentType
-//	}
-//	return nil
-//}
+// The dataset is optional, spans without one go to the dataset named after their service
+func (ri *RequestInfo) ValidateTracesHeaders() error {
+	//if len(ri.ApiKey) == 0 {
+	//	return ErrMissingAPIKeyHeader
+	//}
+	if ri.ContentType != "application/protobuf" && ri.ContentType != "application/x-protobuf" {
+		return ErrInvalidContentType
+	}
+	return nil
+}
 
 // ValidateMetricsHeaders validates required headers/metadata for a metric OTLP request
 //func (ri *RequestInfo) ValidateMetricsHeaders() error {
@@ -68,38 +76,24 @@ type RequestInfo struct {
 //}
 
 // GetRequestInfoFromGrpcMetadata parses relevant gRPC metadata from an incoming request context
-func GetRequestInfoFromGrpcMetadata(ctx context.Context) RequestInfo {
-	ri := RequestInfo{
-		ContentType: "application/protobuf",
-	}
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		//ri.ApiKey = getValueFromMetadata(md, apiKeyHeader)
-		ri.Dataset = getValueFromMetadata(md, datasetHeader)
-		//ri.ProxyToken = getValueFromMetadata(md, proxyTokenHeader)
-		//ri.ProxyVersion = getValueFromMetadata(md, proxyVersionHeader)
-		//ri.UserAgent = getValueFromMetadata(md, userAgentHeader)
-	//	ri.ContentEncoding = getValueFromMetadata(md, contentEncodingHeader)
-		//ri.GRPCAcceptEncoding = getValueFromMetadata(md, gRPCAcceptEncodingHeader)
-		ri.ApiToken = getValueFromMetadata(md, apiTokenHeader)
-		ri.ApiTenantId = getValueFromMetadata(md, apiTenantId)
-	}
-	return ri
+func GetRequestInfoFromGrpcMetadata(ctx context.Context, opts ...RequestInfoOption) RequestInfo {
+	cfg := newRequestInfoConfig(opts)
+	return cfg.mapping.requestInfoFromMetadata(ctx)
 }
 
 // GetRequestInfoFromHttpHeaders parses relevant incoming HTTP headers
-func GetRequestInfoFromHttpHeaders(header http.Header) RequestInfo {
-	return RequestInfo{
-		//ApiKey:             header.Get(apiKeyHeader),
-		Dataset:            header.Get(datasetHeader),
-		//ProxyToken:         header.Get(proxyTokenHeader),
-		//ProxyVersion:       header.Get(proxyVersionHeader),
-		//UserAgent:          header.Get(userAgentHeader),
-		ContentType:        header.Get(contentTypeHeader),
-		//ContentEncoding:    header.Get(contentEncodingHeader),
-		//GRPCAcceptEncoding: header.Get(gRPCAcceptEncodingHeader),
-		ApiToken:           header.Get(apiTokenHeader),
-		ApiTenantId:        header.Get(apiTenantId),
-	}
+func GetRequestInfoFromHttpHeaders(header http.Header, opts ...RequestInfoOption) RequestInfo {
+	cfg := newRequestInfoConfig(opts)
+	return cfg.mapping.requestInfoFromHeaders(header, nil)
+}
+
+// GetRequestInfoFromHttpRequest parses relevant incoming HTTP headers, falling back to the
+// query parameters configured in the header mapping
+func GetRequestInfoFromHttpRequest(r *http.Request, opts ...RequestInfoOption) RequestInfo {
+	cfg := newRequestInfoConfig(opts)
+	ri := cfg.mapping.requestInfoFromHeaders(r.Header, r.URL.Query())
+	ri.RemoteAddr = r.RemoteAddr
+	return ri
 }
 
 func getValueFromMetadata(md metadata.MD, key string) string {
@@ -109,16 +103,50 @@ func getValueFromMetadata(md metadata.MD, key string) string {
 	return ""
 }
 
-func addAttributesToMap(attrs map[string]interface{}, attributes []*common.KeyValue) {
+// attributeCounts counts the attributes a translation ignored or shortened
+type attributeCounts struct {
+	dropped   int
+	truncated int
+}
+
+func (c *attributeCounts) add(other attributeCounts) {
+	c.dropped += other.dropped
+	c.truncated += other.truncated
+}
+
+// addAttributesToMap copies the attributes into attrs, counting the ones ignored and
+// the string values truncated to fieldSizeMax
+func addAttributesToMap(attrs map[string]interface{}, attributes []*common.KeyValue) attributeCounts {
+	var counts attributeCounts
 	for _, attr := range attributes {
 		// ignore entries if the key is empty or value is nil
 		if attr.Key == "" || attr.Value == nil {
+			counts.dropped++
+			continue
+		}
+		val := getValue(attr.Value)
+		if val == nil {
+			counts.dropped++
 			continue
 		}
-		if val := getValue(attr.Value); val != nil {
-			attrs[attr.Key] = val
+		if str, ok := val.(string); ok && len(str) > fieldSizeMax {
+			val = truncateString(str, fieldSizeMax)
+			counts.truncated++
 		}
+		attrs[attr.Key] = val
+	}
+	return counts
+}
+
+// truncateString cuts s to at most max bytes without splitting a UTF-8 sequence
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
 	}
+	return s[:max]
 }
 
 func getValue(value *common.AnyValue) interface{} {
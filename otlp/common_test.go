@@ -3,6 +3,7 @@ package otlp
 import (
 	"context"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -123,34 +124,45 @@ func TestAddAttributesToMap(t *testing.T) {
 	}
 }
 
-//func TestValidateTracesHeaders(t *testing.T) {
-//	testCases := []struct {
-//		apikey      string
-//		dataset     string
-//		contentType string
-//		err         error
-//	}{
-//		//{apikey: "", dataset: "", contentType: "", err: ErrMissingAPIKeyHeader},
-//		{apikey: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", dataset: "", contentType: "", err: ErrMissingDatasetHeader},
-//		{apikey: "abc123DEF456ghi789jklm", dataset: "", contentType: "application/protobuf", err: nil},
-//		//{apikey: "", dataset: "dataset", contentType: "", err: ErrMissingAPIKeyHeader},
-//		{apikey: "apikey", dataset: "dataset", contentType: "", err: ErrInvalidContentType},
-//		{apikey: "apikey", dataset: "dataset", contentType: "application/json", err: ErrInvalidContentType},
-//		{apikey: "apikey", dataset: "dataset", contentType: "application/javascript", err: ErrInvalidContentType},
-//		{apikey: "apikey", dataset: "dataset", contentType: "application/xml", err: ErrInvalidContentType},
-//		{apikey: "apikey", dataset: "dataset", contentType: "application/octet-stream", err: ErrInvalidContentType},
-//		{apikey: "apikey", dataset: "dataset", contentType: "text-plain", err: ErrInvalidContentType},
-//		{apikey: "apikey", dataset: "dataset", contentType: "application/protobuf", err: nil},
-//		{apikey: "apikey", dataset: "dataset", contentType: "application/x-protobuf", err: nil},
-//	}
-//
-//	for _, tc := range testCases {
-//		ri := RequestInfo{ ContentType: tc.contentType, Dataset: tc.dataset}
-//		err := ri.ValidateTracesHeaders()
-//		assert.Equal(t, tc.err, err)
-//	}
-//}
-//
+func TestAddAttributesToMapTruncatesLongStrings(t *testing.T) {
+	attrs := map[string]interface{}{}
+	counts := addAttributesToMap(attrs, []*common.KeyValue{
+		stringAttr("long", strings.Repeat("a", fieldSizeMax+10)),
+		stringAttr("multibyte", strings.Repeat("a", fieldSizeMax-1)+"é"),
+		stringAttr("short", "value"),
+		{Key: "", Value: nil},
+	})
+	assert.Equal(t, attributeCounts{dropped: 1, truncated: 2}, counts)
+	assert.Equal(t, fieldSizeMax, len(attrs["long"].(string)))
+	assert.Equal(t, strings.Repeat("a", fieldSizeMax-1), attrs["multibyte"])
+	assert.Equal(t, "value", attrs["short"])
+}
+
+func TestValidateTracesHeaders(t *testing.T) {
+	testCases := []struct {
+		dataset     string
+		contentType string
+		err         error
+	}{
+		{dataset: "", contentType: "", err: ErrInvalidContentType},
+		{dataset: "", contentType: "application/protobuf", err: nil},
+		{dataset: "dataset", contentType: "", err: ErrInvalidContentType},
+		{dataset: "dataset", contentType: "application/json", err: ErrInvalidContentType},
+		{dataset: "dataset", contentType: "application/javascript", err: ErrInvalidContentType},
+		{dataset: "dataset", contentType: "application/xml", err: ErrInvalidContentType},
+		{dataset: "dataset", contentType: "application/octet-stream", err: ErrInvalidContentType},
+		{dataset: "dataset", contentType: "text-plain", err: ErrInvalidContentType},
+		{dataset: "dataset", contentType: "application/protobuf", err: nil},
+		{dataset: "dataset", contentType: "application/x-protobuf", err: nil},
+	}
+
+	for _, tc := range testCases {
+		ri := RequestInfo{ContentType: tc.contentType, Dataset: tc.dataset}
+		err := ri.ValidateTracesHeaders()
+		assert.Equal(t, tc.err, err)
+	}
+}
+
 //func TestValidateMetricsHeaders(t *testing.T) {
 //	testCases := []struct {
 //		apikey      string
@@ -223,7 +235,7 @@ func TestGetRequestInfoFromGrpcMetadataIsCaseInsensitive(t *testing.T) {
 			ri := GetRequestInfoFromGrpcMetadata(ctx)
 		//	assert.Equal(t, apiKeyValue, ri.ApiKey)
 			assert.Equal(t, datasetValue, ri.Dataset)
-			assert.Equal(t, proxyTokenValue, ri.ProxyToken)
+			//assert.Equal(t, proxyTokenValue, ri.ProxyToken)
 		})
 	}
 }
@@ -266,12 +278,12 @@ func TestGetRequestInfoFromHttpHeadersIsCaseInsensitive(t *testing.T) {
 			header := http.Header{}
 		//	header.Set(apiKeyHeader, apiKeyValue)
 			header.Set(datasetHeader, datasetValue)
-			header.Set(proxyTokenHeader, proxyTokenValue)
+			//header.Set(proxyTokenHeader, proxyTokenValue)
 
 			ri := GetRequestInfoFromHttpHeaders(header)
 		//	assert.Equal(t, apiKeyValue, ri.ApiKey)
 			assert.Equal(t, datasetValue, ri.Dataset)
-			assert.Equal(t, proxyTokenValue, ri.ProxyToken)
+			//assert.Equal(t, proxyTokenValue, ri.ProxyToken)
 		})
 	}
 }
@@ -0,0 +1,101 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// TraceIDBytes returns the 16 byte OTLP form of a trace ID split into its high and low 64 bits
+// 64-bit IDs have their high bytes zeroed, which BytesToTraceID then trims
+func TraceIDBytes(high uint64, low uint64) []byte {
+	b := make([]byte, traceIDLongLength)
+	binary.BigEndian.PutUint64(b[:traceIDShortLength], high)
+	binary.BigEndian.PutUint64(b[traceIDShortLength:], low)
+	return b
+}
+
+// SpanIDBytes returns the 8 byte OTLP form of a 64-bit span ID
+func SpanIDBytes(id uint64) []byte {
+	b := make([]byte, traceIDShortLength)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+// SpanStatusFromAttributes derives the OTLP status of a span converted from a format without one
+// An error tag marks the span as failed, its value is the message unless it is a boolean or "true".
+// Spans without one use the otel.status_code and otel.status_description tags set by OpenTelemetry exporters
+func SpanStatusFromAttributes(attrs []*common.KeyValue) *trace.Status {
+	var errorTag, statusCode, description *common.AnyValue
+	for _, attr := range attrs {
+		switch attr.GetKey() {
+		case "error":
+			errorTag = attr.Value
+		case "otel.status_code":
+			statusCode = attr.Value
+		case "otel.status_description":
+			description = attr.Value
+		}
+	}
+
+	message := description.GetStringValue()
+	switch v := errorTag.GetValue().(type) {
+	case *common.AnyValue_BoolValue:
+		if v.BoolValue {
+			return &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: message}
+		}
+	case *common.AnyValue_StringValue:
+		switch v.StringValue {
+		case "false":
+		case "true":
+			return &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: message}
+		default:
+			return &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: v.StringValue}
+		}
+	}
+
+	switch strings.ToUpper(statusCode.GetStringValue()) {
+	case "ERROR":
+		return &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: message}
+	case "OK":
+		return &trace.Status{Code: trace.Status_STATUS_CODE_OK}
+	}
+	return nil
+}
+
+// NewAttribute returns the OTLP attribute for a tag decoded from another tracing format
+// Strings, booleans, integers and floats keep their type, nil values are left empty and
+// anything else, such as a nested JSON object, is stored as its JSON encoding
+func NewAttribute(key string, value interface{}) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: newAnyValue(value)}
+}
+
+func newAnyValue(value interface{}) *common.AnyValue {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(v)}}
+	case int32:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(v)}}
+	case int64:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: v}}
+	case uint32:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(v)}}
+	case float64:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: v}}
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: fmt.Sprint(value)}}
+	}
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: string(encoded)}}
+}
@@ -0,0 +1,55 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestTraceIDBytes(t *testing.T) {
+	assert.Equal(t, "5af7183fb1d4cf5f463ac35c9f6413ad", BytesToTraceID(TraceIDBytes(0x5af7183fb1d4cf5f, 0x463ac35c9f6413ad)))
+	assert.Equal(t, "463ac35c9f6413ad", BytesToTraceID(TraceIDBytes(0, 0x463ac35c9f6413ad)))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0x12, 0x34}, SpanIDBytes(0x1234))
+}
+
+func TestSpanStatusFromAttributes(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		attrs    []*common.KeyValue
+		expected *trace.Status
+	}{
+		{"no tags", nil, nil},
+		{"error message", []*common.KeyValue{NewAttribute("error", "connection reset")}, &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "connection reset"}},
+		{"error true", []*common.KeyValue{NewAttribute("error", "true"), NewAttribute("otel.status_description", "failed")}, &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "failed"}},
+		{"error bool", []*common.KeyValue{NewAttribute("error", true)}, &trace.Status{Code: trace.Status_STATUS_CODE_ERROR}},
+		{"error false", []*common.KeyValue{NewAttribute("error", false), NewAttribute("otel.status_code", "OK")}, &trace.Status{Code: trace.Status_STATUS_CODE_OK}},
+		{"otel error", []*common.KeyValue{NewAttribute("otel.status_code", "error"), NewAttribute("otel.status_description", "failed")}, &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "failed"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assert.Equal(t, tC.expected, SpanStatusFromAttributes(tC.attrs))
+		})
+	}
+}
+
+func TestNewAttribute(t *testing.T) {
+	attrs := map[string]interface{}{}
+	counts := addAttributesToMap(attrs, []*common.KeyValue{
+		NewAttribute("string", "value"),
+		NewAttribute("int", 3),
+		NewAttribute("float", 1.5),
+		NewAttribute("bool", true),
+		NewAttribute("object", map[string]interface{}{"key": "value"}),
+		NewAttribute("nil", nil),
+	})
+	assert.Equal(t, map[string]interface{}{
+		"string": "value",
+		"int":    int64(3),
+		"float":  1.5,
+		"bool":   true,
+		"object": `{"key":"value"}`,
+	}, attrs)
+	assert.Equal(t, 1, counts.dropped)
+}
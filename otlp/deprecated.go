@@ -0,0 +1,95 @@
+package otlp
+
+import (
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Field numbers of deprecated fields that are no longer in the generated OTLP types
+// Older SDKs still send them, so they are read from the unknown fields of the message
+const (
+	// instrumentationLibrarySpansField is ResourceSpans.instrumentation_library_spans, which has
+	// the same wire format as ScopeSpans
+	instrumentationLibrarySpansField protowire.Number = 1000
+	// deprecatedStatusCodeField is Status.deprecated_code
+	deprecatedStatusCodeField protowire.Number = 1
+
+	deprecatedStatusCodeOK           = 0
+	deprecatedStatusCodeUnknownError = 2
+)
+
+// getScopeSpans returns the scope spans of resourceSpans followed by any sent in the deprecated
+// instrumentation_library_spans field
+func getScopeSpans(resourceSpans *trace.ResourceSpans) []*trace.ScopeSpans {
+	scopeSpans := resourceSpans.ScopeSpans
+	unknown := resourceSpans.ProtoReflect().GetUnknown()
+	if len(unknown) == 0 {
+		return scopeSpans
+	}
+
+	var librarySpans []*trace.ScopeSpans
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			break
+		}
+		unknown = unknown[n:]
+		if num == instrumentationLibrarySpansField && typ == protowire.BytesType {
+			value, m := protowire.ConsumeBytes(unknown)
+			if m < 0 {
+				break
+			}
+			s := &trace.ScopeSpans{}
+			if err := proto.Unmarshal(value, s); err == nil {
+				librarySpans = append(librarySpans, s)
+			}
+			unknown = unknown[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			break
+		}
+		unknown = unknown[m:]
+	}
+	if len(librarySpans) == 0 {
+		return scopeSpans
+	}
+	return append(append([]*trace.ScopeSpans{}, scopeSpans...), librarySpans...)
+}
+
+// getDeprecatedStatusCode returns the deprecated status code sent by older SDKs, or OK if there is none
+func getDeprecatedStatusCode(status *trace.Status) int32 {
+	unknown := status.ProtoReflect().GetUnknown()
+	code := int32(deprecatedStatusCodeOK)
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			break
+		}
+		unknown = unknown[n:]
+		if num == deprecatedStatusCodeField && typ == protowire.VarintType {
+			value, m := protowire.ConsumeVarint(unknown)
+			if m < 0 {
+				break
+			}
+			code = int32(value)
+			unknown = unknown[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(num, typ, unknown)
+		if m < 0 {
+			break
+		}
+		unknown = unknown[m:]
+	}
+	return code
+}
+
+// setDeprecatedStatusCode sets the deprecated status code so older receivers see the same status
+func setDeprecatedStatusCode(status *trace.Status, code int32) {
+	b := protowire.AppendTag(nil, deprecatedStatusCodeField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(code))
+	status.ProtoReflect().SetUnknown(b)
+}
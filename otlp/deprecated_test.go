@@ -0,0 +1,99 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// appendInstrumentationLibrarySpans encodes librarySpans the way older SDKs send it
+func appendInstrumentationLibrarySpans(t *testing.T, resourceSpans *trace.ResourceSpans, librarySpans *trace.ScopeSpans) {
+	data, err := proto.Marshal(librarySpans)
+	require.NoError(t, err)
+	b := protowire.AppendTag(resourceSpans.ProtoReflect().GetUnknown(), instrumentationLibrarySpansField, protowire.BytesType)
+	b = protowire.AppendBytes(b, data)
+	resourceSpans.ProtoReflect().SetUnknown(b)
+}
+
+func TestTranslateTraceReqScopeSpans(t *testing.T) {
+	span := newTestSpan("scope_span")
+	span.Flags = 0x101
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{
+			Resource:  &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+			SchemaUrl: "https://opentelemetry.io/schemas/1.9.0",
+			ScopeSpans: []*trace.ScopeSpans{{
+				Scope: &common.InstrumentationScope{
+					Name:       "my-library",
+					Version:    "1.2.3",
+					Attributes: []*common.KeyValue{stringAttr("scope_attr", "val")},
+				},
+				SchemaUrl: "https://opentelemetry.io/schemas/1.12.0",
+				Spans:     []*trace.Span{span},
+			}},
+		}},
+	}
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches[0].Events))
+	ev := result.Batches[0].Events[0]
+	assert.Equal(t, "scope_span", ev.Attributes["spanName"])
+	assert.Equal(t, int64(0x101), ev.Attributes["spanFlags"])
+	assert.Equal(t, map[string]interface{}{
+		"service.name":        "my-service",
+		"resource.schema_url": "https://opentelemetry.io/schemas/1.9.0",
+		"library.name":        "my-library",
+		"library.version":     "1.2.3",
+		"library.schema_url":  "https://opentelemetry.io/schemas/1.12.0",
+		"scope_attr":          "val",
+	}, ev.Attributes["resourceAttributes"])
+}
+
+func TestTranslateTraceReqInstrumentationLibrarySpans(t *testing.T) {
+	resourceSpans := newTestRequest(newTestSpan("scope_span")).ResourceSpans[0]
+	resourceSpans.Resource.Attributes = []*common.KeyValue{stringAttr("service.name", "my-service")}
+	appendInstrumentationLibrarySpans(t, resourceSpans, &trace.ScopeSpans{
+		Scope: &common.InstrumentationScope{Name: "legacy-library", Version: "0.1.0"},
+		Spans: []*trace.Span{newTestSpan("library_span")},
+	})
+
+	// round trip so the deprecated field arrives as it would on the wire
+	data, err := proto.Marshal(&collectortrace.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{resourceSpans}})
+	require.NoError(t, err)
+	req := &collectortrace.ExportTraceServiceRequest{}
+	require.NoError(t, proto.Unmarshal(data, req))
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 2, len(events))
+	assert.Equal(t, "scope_span", events[0].Attributes["spanName"])
+	assert.Equal(t, "library_span", events[1].Attributes["spanName"])
+	resourceAttrs := events[1].Attributes["resourceAttributes"].(map[string]interface{})
+	assert.Equal(t, "legacy-library", resourceAttrs["library.name"])
+	assert.Equal(t, "0.1.0", resourceAttrs["library.version"])
+}
+
+func TestGetSpanStatusCodeDeprecatedCode(t *testing.T) {
+	status := &trace.Status{}
+	assert.Equal(t, trace.Status_STATUS_CODE_UNSET, getSpanStatusCode(status))
+
+	setDeprecatedStatusCode(status, deprecatedStatusCodeUnknownError)
+	data, err := proto.Marshal(status)
+	require.NoError(t, err)
+	decoded := &trace.Status{}
+	require.NoError(t, proto.Unmarshal(data, decoded))
+	assert.Equal(t, int32(deprecatedStatusCodeUnknownError), getDeprecatedStatusCode(decoded))
+	assert.Equal(t, trace.Status_STATUS_CODE_ERROR, getSpanStatusCode(decoded))
+
+	decoded.Code = trace.Status_STATUS_CODE_OK
+	assert.Equal(t, trace.Status_STATUS_CODE_OK, getSpanStatusCode(decoded))
+}
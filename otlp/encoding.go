@@ -0,0 +1,306 @@
+package otlp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// msgpackTimestampExtension is the extension type reserved by the msgpack spec for timestamps
+const msgpackTimestampExtension = -1
+
+// Encoder serialises the events of a Batch to a writer
+// Every event is written as an object with "time", "samplerate" and "data" keys, matching the
+// Honeycomb batch API. samplerate is omitted when the event has no sample rate
+type Encoder interface {
+	Encode(w io.Writer, batch Batch) error
+	ContentType() string
+}
+
+// NDJSONEncoder writes one JSON object per event, each terminated by a newline
+type NDJSONEncoder struct{}
+
+func (NDJSONEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (NDJSONEncoder) Encode(w io.Writer, batch Batch) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+	for _, ev := range batch.Events {
+		if err := encoder.Encode(newBatchEvent(ev)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// HoneycombBatchEncoder writes the events as the JSON array accepted by the Honeycomb /1/batch endpoint
+type HoneycombBatchEncoder struct{}
+
+func (HoneycombBatchEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (HoneycombBatchEncoder) Encode(w io.Writer, batch Batch) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+	bw.WriteByte('[')
+	for i, ev := range batch.Events {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		// json.Encoder terminates every value with a newline, which is valid whitespace inside the array
+		if err := encoder.Encode(newBatchEvent(ev)); err != nil {
+			return err
+		}
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
+
+// MsgpackEncoder writes the events as a msgpack array in the same shape as HoneycombBatchEncoder
+// Times are written using the msgpack timestamp extension (-1)
+type MsgpackEncoder struct{}
+
+func (MsgpackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+func (MsgpackEncoder) Encode(w io.Writer, batch Batch) error {
+	b := msgp.AppendArrayHeader(nil, uint32(len(batch.Events)))
+	var err error
+	for _, ev := range batch.Events {
+		size := uint32(2)
+		if ev.SampleRate > 0 {
+			size++
+		}
+		b = msgp.AppendMapHeader(b, size)
+		b = msgp.AppendString(b, "time")
+		b = appendMsgpackTime(b, ev.Timestamp)
+		if ev.SampleRate > 0 {
+			b = msgp.AppendString(b, "samplerate")
+			b = msgp.AppendInt32(b, ev.SampleRate)
+		}
+		b = msgp.AppendString(b, "data")
+		if b, err = appendMsgpackValue(b, ev.Attributes); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// CompressedEncoder compresses the output of another Encoder with gzip or zstd
+type CompressedEncoder struct {
+	encoder  Encoder
+	encoding string
+}
+
+// NewCompressedEncoder wraps an encoder so its output is compressed, encoding is "gzip" or "zstd"
+func NewCompressedEncoder(encoder Encoder, encoding string) (*CompressedEncoder, error) {
+	switch encoding {
+	case "gzip", "zstd":
+		return &CompressedEncoder{encoder: encoder, encoding: encoding}, nil
+	}
+	return nil, fmt.Errorf("unsupported content-encoding '%s'", encoding)
+}
+
+func (c *CompressedEncoder) ContentType() string {
+	return c.encoder.ContentType()
+}
+
+// ContentEncoding returns the value to send in the content-encoding header
+func (c *CompressedEncoder) ContentEncoding() string {
+	return c.encoding
+}
+
+func (c *CompressedEncoder) Encode(w io.Writer, batch Batch) error {
+	var compressor io.WriteCloser
+	switch c.encoding {
+	case "gzip":
+		compressor = gzip.NewWriter(w)
+	case "zstd":
+		zstdWriter, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		compressor = zstdWriter
+	}
+	if err := c.encoder.Encode(compressor, batch); err != nil {
+		compressor.Close()
+		return err
+	}
+	return compressor.Close()
+}
+
+type batchEvent struct {
+	Time       string                 `json:"time"`
+	SampleRate int32                  `json:"samplerate,omitempty"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+func newBatchEvent(ev Event) batchEvent {
+	data, _ := jsonSafeAttributes(ev.Attributes)
+	return batchEvent{
+		Time:       ev.Timestamp.UTC().Format(time.RFC3339Nano),
+		SampleRate: ev.SampleRate,
+		Data:       data,
+	}
+}
+
+// jsonSafeAttributes replaces non-finite floats, which encoding/json rejects, with their string
+// form. Integers are left as int64/uint64 so they are written without losing precision.
+// The attributes are only copied when something had to be replaced
+func jsonSafeAttributes(attrs map[string]interface{}) (map[string]interface{}, bool) {
+	var safe map[string]interface{}
+	for k, v := range attrs {
+		converted, changed := jsonSafeValue(v)
+		if !changed {
+			continue
+		}
+		if safe == nil {
+			safe = make(map[string]interface{}, len(attrs))
+			for k, v := range attrs {
+				safe[k] = v
+			}
+		}
+		safe[k] = converted
+	}
+	if safe == nil {
+		return attrs, false
+	}
+	return safe, true
+}
+
+func jsonSafeValue(v interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Sprint(val), true
+		}
+	case float32:
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return fmt.Sprint(val), true
+		}
+	case map[string]interface{}:
+		return jsonSafeAttributes(val)
+	case []interface{}:
+		return jsonSafeSlice(val)
+	}
+	return v, false
+}
+
+// jsonSafeSlice is jsonSafeAttributes for array values, copying the slice only when an element changed
+func jsonSafeSlice(values []interface{}) ([]interface{}, bool) {
+	var safe []interface{}
+	for i, v := range values {
+		converted, changed := jsonSafeValue(v)
+		if !changed {
+			continue
+		}
+		if safe == nil {
+			safe = make([]interface{}, len(values))
+			copy(safe, values)
+		}
+		safe[i] = converted
+	}
+	if safe == nil {
+		return values, false
+	}
+	return safe, true
+}
+
+func appendMsgpackTime(b []byte, t time.Time) []byte {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data[:4], uint32(t.Nanosecond()))
+	binary.BigEndian.PutUint64(data[4:], uint64(t.Unix()))
+	b, _ = msgp.AppendExtension(b, &msgp.RawExtension{Type: msgpackTimestampExtension, Data: data})
+	return b
+}
+
+func appendMsgpackValue(b []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return msgp.AppendNil(b), nil
+	case string:
+		return msgp.AppendString(b, val), nil
+	case bool:
+		return msgp.AppendBool(b, val), nil
+	case int64:
+		return msgp.AppendInt64(b, val), nil
+	case int:
+		return msgp.AppendInt(b, val), nil
+	case float64:
+		return msgp.AppendFloat64(b, val), nil
+	case []byte:
+		return msgp.AppendBytes(b, val), nil
+	case time.Time:
+		return appendMsgpackTime(b, val), nil
+	case map[string]interface{}:
+		b = msgp.AppendMapHeader(b, uint32(len(val)))
+		var err error
+		for k, item := range val {
+			b = msgp.AppendString(b, k)
+			if b, err = appendMsgpackValue(b, item); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	}
+
+	// fall back to reflection for named types such as trace.Status_StatusCode and other containers
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return msgp.AppendInt64(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return msgp.AppendUint64(b, rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return msgp.AppendFloat64(b, rv.Float()), nil
+	case reflect.String:
+		return msgp.AppendString(b, rv.String()), nil
+	case reflect.Bool:
+		return msgp.AppendBool(b, rv.Bool()), nil
+	case reflect.Slice, reflect.Array:
+		b = msgp.AppendArrayHeader(b, uint32(rv.Len()))
+		var err error
+		for i := 0; i < rv.Len(); i++ {
+			if b, err = appendMsgpackValue(b, rv.Index(i).Interface()); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		b = msgp.AppendMapHeader(b, uint32(rv.Len()))
+		var err error
+		iter := rv.MapRange()
+		for iter.Next() {
+			b = msgp.AppendString(b, iter.Key().String())
+			if b, err = appendMsgpackValue(b, iter.Value().Interface()); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return msgp.AppendNil(b), nil
+		}
+		return appendMsgpackValue(b, rv.Elem().Interface())
+	}
+	return b, fmt.Errorf("unsupported msgpack value of type %T", v)
+}
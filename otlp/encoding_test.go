@@ -0,0 +1,152 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func newEncodingTestBatch() Batch {
+	timestamp := time.Date(2021, 9, 1, 12, 30, 15, 123456789, time.UTC)
+	return Batch{
+		Dataset: "dataset",
+		Events: []Event{
+			{
+				Attributes: map[string]interface{}{
+					"spanName":   "span <one>",
+					"startTime":  int64(math.MaxInt64),
+					"durationMs": 1.5,
+					"statusCode": trace.Status_STATUS_CODE_ERROR,
+					"spanAttributes": map[string]interface{}{
+						"nan":  math.NaN(),
+						"list": []interface{}{1.5, math.Inf(1), map[string]interface{}{"nan": math.NaN()}},
+					},
+				},
+				Timestamp:  timestamp,
+				SampleRate: 10,
+			},
+			{
+				Attributes: map[string]interface{}{"spanName": "span two"},
+				Timestamp:  timestamp.Add(time.Second),
+			},
+		},
+	}
+}
+
+func decodeJSONNumbers(t *testing.T, data []byte) map[string]interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v map[string]interface{}
+	require.NoError(t, decoder.Decode(&v))
+	return v
+}
+
+func TestNDJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NDJSONEncoder{}.Encode(&buf, newEncodingTestBatch()))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, 2, len(lines))
+
+	first := decodeJSONNumbers(t, []byte(lines[0]))
+	assert.Equal(t, "2021-09-01T12:30:15.123456789Z", first["time"])
+	assert.Equal(t, json.Number("10"), first["samplerate"])
+	data := first["data"].(map[string]interface{})
+	assert.Equal(t, "span <one>", data["spanName"])
+	assert.Equal(t, json.Number("9223372036854775807"), data["startTime"])
+	assert.Equal(t, json.Number("2"), data["statusCode"])
+	spanAttrs := data["spanAttributes"].(map[string]interface{})
+	assert.Equal(t, "NaN", spanAttrs["nan"])
+	assert.Equal(t, []interface{}{json.Number("1.5"), "+Inf", map[string]interface{}{"nan": "NaN"}}, spanAttrs["list"])
+
+	second := decodeJSONNumbers(t, []byte(lines[1]))
+	_, ok := second["samplerate"]
+	assert.False(t, ok)
+}
+
+func TestHoneycombBatchEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, HoneycombBatchEncoder{}.Encode(&buf, newEncodingTestBatch()))
+
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+	var events []map[string]interface{}
+	require.NoError(t, decoder.Decode(&events))
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "2021-09-01T12:30:16.123456789Z", events[1]["time"])
+	assert.Equal(t, json.Number("9223372036854775807"), events[0]["data"].(map[string]interface{})["startTime"])
+	assert.Equal(t, "+Inf", events[0]["data"].(map[string]interface{})["spanAttributes"].(map[string]interface{})["list"].([]interface{})[1])
+
+	buf.Reset()
+	require.NoError(t, HoneycombBatchEncoder{}.Encode(&buf, Batch{}))
+	assert.Equal(t, "[]", buf.String())
+}
+
+func TestMsgpackEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, MsgpackEncoder{}.Encode(&buf, newEncodingTestBatch()))
+
+	decoded, rest, err := msgp.ReadIntfBytes(buf.Bytes())
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	events := decoded.([]interface{})
+	assert.Equal(t, 2, len(events))
+
+	first := events[0].(map[string]interface{})
+	assert.Equal(t, int64(10), first["samplerate"])
+	ts := first["time"].(*msgp.RawExtension)
+	assert.Equal(t, int8(-1), ts.Type)
+	assert.Equal(t, uint32(123456789), binary.BigEndian.Uint32(ts.Data[:4]))
+	assert.Equal(t, uint64(time.Date(2021, 9, 1, 12, 30, 15, 0, time.UTC).Unix()), binary.BigEndian.Uint64(ts.Data[4:]))
+
+	data := first["data"].(map[string]interface{})
+	assert.Equal(t, int64(math.MaxInt64), data["startTime"])
+	assert.Equal(t, int64(2), data["statusCode"])
+	assert.Equal(t, 1.5, data["durationMs"])
+	assert.True(t, math.IsNaN(data["spanAttributes"].(map[string]interface{})["nan"].(float64)))
+
+	_, ok := events[1].(map[string]interface{})["samplerate"]
+	assert.False(t, ok)
+}
+
+func TestCompressedEncoder(t *testing.T) {
+	var expected bytes.Buffer
+	require.NoError(t, NDJSONEncoder{}.Encode(&expected, newEncodingTestBatch()))
+
+	gzipEncoder, err := NewCompressedEncoder(NDJSONEncoder{}, "gzip")
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", gzipEncoder.ContentEncoding())
+	assert.Equal(t, "application/x-ndjson", gzipEncoder.ContentType())
+	var buf bytes.Buffer
+	require.NoError(t, gzipEncoder.Encode(&buf, newEncodingTestBatch()))
+	reader, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), string(decompressed))
+
+	zstdEncoder, err := NewCompressedEncoder(NDJSONEncoder{}, "zstd")
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, zstdEncoder.Encode(&buf, newEncodingTestBatch()))
+	zstdReader, err := zstd.NewReader(&buf)
+	require.NoError(t, err)
+	decompressed, err = ioutil.ReadAll(zstdReader)
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), string(decompressed))
+
+	_, err = NewCompressedEncoder(NDJSONEncoder{}, "br")
+	assert.Error(t, err)
+}
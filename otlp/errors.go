@@ -1,37 +1,154 @@
 package otlp
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
+// OTLPError is an error with the HTTP status and gRPC code that should be returned to the client
+// RetryAfter is an optional hint for how long the client should wait before retrying
+// Retryable tells the client it may send the same request again, Err is the underlying cause
+// and Details are extra messages attached to the gRPC status
+// Only Message is sent to the client, the cause is kept for logging and errors.Is
 type OTLPError struct {
 	Message        string
 	HTTPStatusCode int
 	GRPCStatusCode codes.Code
+	RetryAfter     time.Duration
+	Retryable      bool
+	Err            error
+	Details        *ErrorDetails
+}
+
+// ErrorDetails holds the messages attached to the gRPC status, eg errdetails.BadRequest
+// It is kept behind a pointer so OTLPError stays comparable
+type ErrorDetails struct {
+	Messages []proto.Message
 }
 
 var (
-	ErrInvalidContentType   = OTLPError{"invalid content-type - only 'application/protobuf' is supported", http.StatusNotImplemented, codes.Unimplemented}
-	ErrFailedParseBody      = OTLPError{"failed to parse OTLP request body", http.StatusBadRequest, codes.Internal}
-//	ErrMissingAPIKeyHeader  = OTLPError{"missing 'x-opsramp-team' header", http.StatusUnauthorized, codes.Unauthenticated}
-	ErrMissingDatasetHeader = OTLPError{"missing 'x-opsramp-dataset' header", http.StatusUnauthorized, codes.Unauthenticated}
+	ErrInvalidContentType = OTLPError{Message: "invalid content-type - only 'application/protobuf' is supported", HTTPStatusCode: http.StatusNotImplemented, GRPCStatusCode: codes.Unimplemented}
+	ErrFailedParseBody    = OTLPError{Message: "failed to parse OTLP request body", HTTPStatusCode: http.StatusBadRequest, GRPCStatusCode: codes.InvalidArgument}
+	//	ErrMissingAPIKeyHeader  = OTLPError{"missing 'x-opsramp-team' header", http.StatusUnauthorized, codes.Unauthenticated}
+	ErrMissingDatasetHeader = OTLPError{Message: "missing 'x-opsramp-dataset' header", HTTPStatusCode: http.StatusUnauthorized, GRPCStatusCode: codes.Unauthenticated}
+	ErrMissingCredentials   = OTLPError{Message: "missing credentials in 'authorization' header", HTTPStatusCode: http.StatusUnauthorized, GRPCStatusCode: codes.Unauthenticated}
+	ErrInvalidCredentials   = OTLPError{Message: "invalid credentials", HTTPStatusCode: http.StatusUnauthorized, GRPCStatusCode: codes.Unauthenticated}
+	ErrRateLimited          = OTLPError{Message: "rate limit exceeded", HTTPStatusCode: http.StatusTooManyRequests, GRPCStatusCode: codes.ResourceExhausted, Retryable: true}
 )
 
+// internalErrorMessage is sent to clients in place of the message of errors that are not OTLPErrors
+const internalErrorMessage = "internal error"
+
 func (e OTLPError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the underlying cause of the error
+func (e OTLPError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the same kind of OTLPError, ignoring the cause, retry hint and details
+// so errors with any of them set still match the predefined errors
+func (e OTLPError) Is(target error) bool {
+	t, ok := target.(OTLPError)
+	return ok && t.Message == e.Message && t.HTTPStatusCode == e.HTTPStatusCode && t.GRPCStatusCode == e.GRPCStatusCode
+}
+
+// Wrap returns a copy of the error with err as its cause
+func (e OTLPError) Wrap(err error) OTLPError {
+	e.Err = err
+	return e
+}
+
+// WithDetails returns a copy of the error with messages attached to its gRPC status
+func (e OTLPError) WithDetails(messages ...proto.Message) OTLPError {
+	e.Details = &ErrorDetails{Messages: messages}
+	return e
+}
+
 func AsJson(e error) string {
-	return fmt.Sprintf(`{"message":"%s"}`, e.Error())
+	b, err := json.Marshal(struct {
+		Message string `json:"message"`
+	}{e.Error()})
+	if err != nil {
+		return `{"message":""}`
+	}
+	return string(b)
 }
 
+// AsGRPCError converts the error into a gRPC status error
+// Errors that are not OTLPErrors or gRPC status errors are returned as a generic Internal error,
+// their message is logged rather than sent to the client
 func AsGRPCError(e error) error {
-	if otlpErr, ok := e.(OTLPError); ok {
-		return status.Error(otlpErr.GRPCStatusCode, otlpErr.Message)
+	return asStatus(e).Err()
+}
+
+// AsStatus returns the google.rpc.Status describing the error, as returned in OTLP/HTTP error responses
+func AsStatus(e error) *spb.Status {
+	return asStatus(e).Proto()
+}
+
+// MarshalStatus encodes the google.rpc.Status for the error in the content type of the request,
+// JSON for application/json and protobuf otherwise, returning the body and its content type
+func MarshalStatus(e error, contentType string) ([]byte, string, error) {
+	st := AsStatus(e)
+	if strings.HasPrefix(contentType, "application/json") {
+		b, err := protojson.Marshal(st)
+		return b, "application/json", err
+	}
+	b, err := proto.Marshal(st)
+	return b, "application/x-protobuf", err
+}
+
+func asStatus(e error) *status.Status {
+	var otlpErr OTLPError
+	if !errors.As(e, &otlpErr) {
+		if st, ok := status.FromError(e); ok {
+			return st
+		}
+		log.Printf("otlp: internal error: %v", e)
+		return status.New(codes.Internal, internalErrorMessage)
+	}
+
+	st := status.New(otlpErr.GRPCStatusCode, otlpErr.Message)
+	var details []protoadapt.MessageV1
+	if otlpErr.RetryAfter > 0 {
+		details = append(details, protoadapt.MessageV1Of(&errdetails.RetryInfo{RetryDelay: durationpb.New(otlpErr.RetryAfter)}))
+	}
+	if otlpErr.Details != nil {
+		for _, detail := range otlpErr.Details.Messages {
+			details = append(details, protoadapt.MessageV1Of(detail))
+		}
+	}
+	if len(details) > 0 {
+		if detailed, err := st.WithDetails(details...); err == nil {
+			st = detailed
+		}
+	}
+	return st
+}
+
+// SetRetryAfterHeader sets the HTTP Retry-After header, in whole seconds, when the error carries a retry hint
+func SetRetryAfterHeader(header http.Header, e error) {
+	var otlpErr OTLPError
+	if errors.As(e, &otlpErr) && otlpErr.RetryAfter > 0 {
+		seconds := int64(math.Ceil(otlpErr.RetryAfter.Seconds()))
+		header.Set("Retry-After", strconv.FormatInt(seconds, 10))
 	}
-	return status.Error(codes.Internal, "")
 }
@@ -1,11 +1,19 @@
 package otlp
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestErrorsReturnJson(t *testing.T) {
@@ -20,5 +28,72 @@ func TestAsGRPCError(t *testing.T) {
 
 func TestNonOTLPErrorReturnsStandardError(t *testing.T) {
 	err := errors.New("base-error")
-	assert.Equal(t, "rpc error: code = Internal desc = ", AsGRPCError(err).Error())
+	assert.Equal(t, "rpc error: code = Internal desc = internal error", AsGRPCError(err).Error())
+}
+
+func TestAsJsonEscapesMessage(t *testing.T) {
+	err := OTLPError{Message: `invalid "value"` + "\n"}
+	assert.Equal(t, `{"message":"invalid \"value\"\n"}`, AsJson(err))
+}
+
+func TestGRPCStatusErrorIsUnchanged(t *testing.T) {
+	err := status.Error(codes.Unavailable, "downstream unavailable")
+	assert.Equal(t, err, AsGRPCError(err))
+}
+
+func TestFailedParseBodyIsInvalidArgument(t *testing.T) {
+	assert.Equal(t, codes.InvalidArgument, status.Code(AsGRPCError(ErrFailedParseBody)))
+}
+
+func TestWrappedOTLPError(t *testing.T) {
+	cause := errors.New("unexpected EOF")
+	err := fmt.Errorf("decoding: %w", ErrFailedParseBody.Wrap(cause))
+
+	assert.True(t, errors.Is(err, ErrFailedParseBody))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrInvalidContentType))
+	// the cause is not sent to the client
+	assert.Equal(t, "rpc error: code = InvalidArgument desc = failed to parse OTLP request body", AsGRPCError(err).Error())
+}
+
+func TestOTLPErrorIsComparable(t *testing.T) {
+	var err error = ErrFailedParseBody
+	assert.True(t, err == ErrFailedParseBody)
+
+	err = ErrFailedParseBody.Wrap(errors.New("cause")).WithDetails(&errdetails.BadRequest{})
+	assert.NotPanics(t, func() { assert.False(t, err == ErrFailedParseBody) })
+	assert.True(t, errors.Is(err, ErrFailedParseBody))
+	assert.Equal(t, ErrFailedParseBody.Message, err.Error())
+}
+
+func TestAsGRPCErrorDetails(t *testing.T) {
+	err := OTLPError{
+		Message:        "invalid span",
+		GRPCStatusCode: codes.InvalidArgument,
+		RetryAfter:     time.Second,
+	}.WithDetails(&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+		{Field: "span_id", Description: "must be 8 bytes"},
+	}})
+	details := status.Convert(AsGRPCError(err)).Details()
+	require.Equal(t, 2, len(details))
+	assert.Equal(t, time.Second, details[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+	assert.Equal(t, "span_id", details[1].(*errdetails.BadRequest).FieldViolations[0].Field)
+}
+
+func TestMarshalStatus(t *testing.T) {
+	body, contentType, err := MarshalStatus(ErrFailedParseBody, "application/json")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, float64(codes.InvalidArgument), decoded["code"])
+	assert.Equal(t, "failed to parse OTLP request body", decoded["message"])
+
+	body, contentType, err = MarshalStatus(ErrRateLimited, "application/x-protobuf")
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-protobuf", contentType)
+	st := &spb.Status{}
+	require.NoError(t, proto.Unmarshal(body, st))
+	assert.Equal(t, int32(codes.ResourceExhausted), st.Code)
+	assert.Equal(t, "rate limit exceeded", st.Message)
 }
@@ -0,0 +1,98 @@
+package otlp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	exceptionEventName         = "exception"
+	exceptionTypeKey           = "exception.type"
+	exceptionMessageKey        = "exception.message"
+	exceptionStacktraceKey     = "exception.stacktrace"
+	stacktraceFingerprintBytes = 8
+)
+
+// stackNoisePattern matches the parts of a stack trace line that change between builds or runs
+var stackNoisePattern = regexp.MustCompile(`0x[0-9a-fA-F]+|\d+`)
+
+type exception struct {
+	Type       string `json:"type,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Stacktrace string `json:"stacktrace,omitempty"`
+}
+
+// addExceptionFields promotes the attributes of the span's exception events to top-level fields
+// The first exception recorded, usually the one that started the failure, fills exceptionType, exceptionMessage,
+// exceptionStacktrace and exceptionFingerprint. Spans with more than one also get all of them as a JSON array in exceptions
+// It returns whether the span had an exception event
+func addExceptionFields(eventAttrs map[string]interface{}, events []*trace.Span_Event) bool {
+	var exceptions []exception
+	for _, event := range events {
+		if event.Name != exceptionEventName {
+			continue
+		}
+		var ex exception
+		for _, attr := range event.Attributes {
+			if attr.Value == nil {
+				continue
+			}
+			switch attr.Key {
+			case exceptionTypeKey:
+				ex.Type = attr.Value.GetStringValue()
+			case exceptionMessageKey:
+				ex.Message = attr.Value.GetStringValue()
+			case exceptionStacktraceKey:
+				ex.Stacktrace = attr.Value.GetStringValue()
+			}
+		}
+		exceptions = append(exceptions, ex)
+	}
+	if len(exceptions) == 0 {
+		return false
+	}
+
+	first := exceptions[0]
+	if first.Type != "" {
+		eventAttrs["exceptionType"] = first.Type
+	}
+	if first.Message != "" {
+		eventAttrs["exceptionMessage"] = first.Message
+	}
+	if first.Stacktrace != "" {
+		eventAttrs["exceptionStacktrace"] = first.Stacktrace
+		eventAttrs["exceptionFingerprint"] = StacktraceFingerprint(first.Type, first.Stacktrace)
+	}
+	eventAttrs["exceptionCount"] = len(exceptions)
+	if len(exceptions) > 1 {
+		if bytes, err := json.Marshal(exceptions); err == nil {
+			eventAttrs["exceptions"] = string(bytes)
+		}
+	}
+	return true
+}
+
+// StacktraceFingerprint returns a hash of the exception type and stack trace that ignores addresses,
+// line numbers and file directories so the same error groups together across builds
+// Stack traces ParseStacktrace cannot parse are hashed line by line without addresses and numbers
+func StacktraceFingerprint(exceptionType string, stacktrace string) string {
+	if parsed := ParseStacktrace(stacktrace); len(parsed.Frames) > 0 {
+		return parsed.Fingerprint(exceptionType)
+	}
+	h := sha256.New()
+	h.Write([]byte(exceptionType))
+	for _, line := range strings.Split(stacktrace, "\n") {
+		line = strings.TrimSpace(stackNoisePattern.ReplaceAllString(line, ""))
+		if line == "" {
+			continue
+		}
+		h.Write([]byte{'\n'})
+		h.Write([]byte(line))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:stacktraceFingerprintBytes])
+}
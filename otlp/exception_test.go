@@ -0,0 +1,114 @@
+package otlp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const goStacktrace = `goroutine 1 [running]:
+main.handler(0xc000012345)
+	/app/main.go:42 +0x1d
+main.main()
+	/app/main.go:10 +0x25`
+
+func newExceptionEvent(exceptionType, message, stacktrace string) *trace.Span_Event {
+	return &trace.Span_Event{
+		Name: exceptionEventName,
+		Attributes: []*common.KeyValue{
+			stringAttr(exceptionTypeKey, exceptionType),
+			stringAttr(exceptionMessageKey, message),
+			stringAttr(exceptionStacktraceKey, stacktrace),
+		},
+	}
+}
+
+func newExceptionSpan(status *trace.Status, events ...*trace.Span_Event) *trace.Span {
+	span := newTestSpan("span")
+	span.Status = status
+	span.Events = events
+	return span
+}
+
+func TestTranslateTraceReqExceptionFields(t *testing.T) {
+	req := newTestRequest(newExceptionSpan(nil,
+		&trace.Span_Event{Name: "retry"},
+		newExceptionEvent("*errors.errorString", "panic: boom", goStacktrace),
+	))
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "*errors.errorString", attrs["exceptionType"])
+	assert.Equal(t, "panic: boom", attrs["exceptionMessage"])
+	assert.Equal(t, goStacktrace, attrs["exceptionStacktrace"])
+	assert.Equal(t, StacktraceFingerprint("*errors.errorString", goStacktrace), attrs["exceptionFingerprint"])
+	assert.Equal(t, 1, attrs["exceptionCount"])
+	assert.NotContains(t, attrs, "exceptions")
+	// the status is unset so error is only set when configured
+	assert.Equal(t, false, attrs["error"])
+}
+
+func TestTranslateTraceReqMultipleExceptions(t *testing.T) {
+	req := newTestRequest(newExceptionSpan(nil,
+		newExceptionEvent("*net.OpError", "dial tcp: connection refused", ""),
+		&trace.Span_Event{Name: "retry"},
+		newExceptionEvent("*errors.errorString", "panic: boom", goStacktrace),
+	))
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	// the first exception is promoted
+	assert.Equal(t, "*net.OpError", attrs["exceptionType"])
+	assert.Equal(t, "dial tcp: connection refused", attrs["exceptionMessage"])
+	assert.NotContains(t, attrs, "exceptionStacktrace")
+	assert.NotContains(t, attrs, "exceptionFingerprint")
+	assert.Equal(t, 2, attrs["exceptionCount"])
+
+	var exceptions []map[string]string
+	require.NoError(t, json.Unmarshal([]byte(attrs["exceptions"].(string)), &exceptions))
+	assert.Equal(t, []map[string]string{
+		{"type": "*net.OpError", "message": "dial tcp: connection refused"},
+		{"type": "*errors.errorString", "message": "panic: boom", "stacktrace": goStacktrace},
+	}, exceptions)
+}
+
+func TestTranslateTraceReqWithErrorOnException(t *testing.T) {
+	exception := newExceptionEvent("ValueError", "bad value", "")
+	tests := []struct {
+		name     string
+		status   *trace.Status
+		events   []*trace.Span_Event
+		expected bool
+	}{
+		{name: "unset status", events: []*trace.Span_Event{exception}, expected: true},
+		{name: "ok status", status: &trace.Status{Code: trace.Status_STATUS_CODE_OK}, events: []*trace.Span_Event{exception}, expected: false},
+		{name: "error status", status: &trace.Status{Code: trace.Status_STATUS_CODE_ERROR}, expected: true},
+		{name: "no exception", events: []*trace.Span_Event{{Name: "retry"}}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := TranslateTraceReq(newTestRequest(newExceptionSpan(tt.status, tt.events...)), RequestInfo{Dataset: "dataset"}, WithErrorOnException())
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Batches[0].Events[0].Attributes["error"])
+		})
+	}
+}
+
+func TestStacktraceFingerprint(t *testing.T) {
+	moved := `goroutine 7 [running]:
+main.handler(0xc000098765)
+	/app/main.go:48 +0x2f
+main.main()
+	/app/main.go:12 +0x25
+`
+	assert.Equal(t, StacktraceFingerprint("panic", goStacktrace), StacktraceFingerprint("panic", moved))
+	assert.Equal(t, 16, len(StacktraceFingerprint("panic", goStacktrace)))
+	assert.NotEqual(t, StacktraceFingerprint("panic", goStacktrace), StacktraceFingerprint("error", goStacktrace))
+	assert.NotEqual(t, StacktraceFingerprint("panic", goStacktrace), StacktraceFingerprint("panic", "main.other()\n\t/app/other.go:1"))
+}
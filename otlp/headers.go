@@ -0,0 +1,207 @@
+package otlp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// HeaderNames lists the headers or gRPC metadata keys checked for a RequestInfo field, in order
+// QueryParams are only checked for HTTP requests when none of the headers are set, for browser
+// exporters that cannot send custom headers
+type HeaderNames struct {
+	Headers     []string
+	QueryParams []string
+}
+
+// HeaderMapping configures where the fields of RequestInfo are read from
+// Fields left empty use the names from DefaultHeaderMapping
+// Extra lists additional headers captured into RequestInfo.Extra
+// Query parameters for ApiToken, ApiTenantId and ProxyToken are ignored unless AllowCredentialQueryParams is set,
+// credentials sent in the URL end up in proxy and access logs
+type HeaderMapping struct {
+	Dataset                    HeaderNames
+	ProxyToken                 HeaderNames
+	ProxyVersion               HeaderNames
+	UserAgent                  HeaderNames
+	ApiToken                   HeaderNames
+	ApiTenantId                HeaderNames
+	ContentType                HeaderNames
+	ContentEncoding            HeaderNames
+	Extra                      []string
+	AllowCredentialQueryParams bool
+}
+
+// DefaultHeaderMapping returns the header names used when no mapping is configured
+func DefaultHeaderMapping() HeaderMapping {
+	return HeaderMapping{
+		Dataset:      HeaderNames{Headers: []string{datasetHeader}},
+		ProxyToken:   HeaderNames{Headers: []string{proxyTokenHeader}},
+		ProxyVersion: HeaderNames{Headers: []string{proxyVersionHeader}},
+		UserAgent:    HeaderNames{Headers: []string{userAgentHeader}},
+		ApiToken:     HeaderNames{Headers: []string{apiTokenHeader}},
+		ApiTenantId:  HeaderNames{Headers: []string{apiTenantId}},
+		ContentType:  HeaderNames{Headers: []string{contentTypeHeader}},
+	}
+}
+
+// RequestInfoOption configures how RequestInfo is parsed from a request
+type RequestInfoOption func(*requestInfoConfig)
+
+type requestInfoConfig struct {
+	mapping HeaderMapping
+}
+
+func newRequestInfoConfig(opts []RequestInfoOption) *requestInfoConfig {
+	cfg := &requestInfoConfig{
+		mapping: DefaultHeaderMapping(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithHeaderMapping reads RequestInfo fields from the given headers, metadata keys and query parameters
+func WithHeaderMapping(mapping HeaderMapping) RequestInfoOption {
+	return func(cfg *requestInfoConfig) {
+		cfg.mapping = mapping.withDefaults()
+	}
+}
+
+func (m HeaderMapping) withDefaults() HeaderMapping {
+	defaults := DefaultHeaderMapping()
+	m.Dataset = m.Dataset.or(defaults.Dataset)
+	m.ProxyToken = m.ProxyToken.or(defaults.ProxyToken)
+	m.ProxyVersion = m.ProxyVersion.or(defaults.ProxyVersion)
+	m.UserAgent = m.UserAgent.or(defaults.UserAgent)
+	m.ApiToken = m.ApiToken.or(defaults.ApiToken)
+	m.ApiTenantId = m.ApiTenantId.or(defaults.ApiTenantId)
+	m.ContentType = m.ContentType.or(defaults.ContentType)
+	m.ContentEncoding = m.ContentEncoding.or(defaults.ContentEncoding)
+	return m
+}
+
+func (n HeaderNames) or(defaults HeaderNames) HeaderNames {
+	if len(n.Headers) == 0 && len(n.QueryParams) == 0 {
+		return defaults
+	}
+	return n
+}
+
+// fromHeaders returns the first non-empty value of the headers, falling back to the query parameters
+func (n HeaderNames) fromHeaders(header http.Header, query url.Values) string {
+	for _, name := range n.Headers {
+		if val := header.Get(name); val != "" {
+			return val
+		}
+	}
+	for _, name := range n.QueryParams {
+		if val := query.Get(name); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+func (n HeaderNames) fromMetadata(md metadata.MD) string {
+	for _, name := range n.Headers {
+		if val := getValueFromMetadata(md, name); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+func (m HeaderMapping) requestInfoFromHeaders(header http.Header, query url.Values) RequestInfo {
+	credentialQuery := query
+	if !m.AllowCredentialQueryParams {
+		credentialQuery = nil
+	}
+	ri := RequestInfo{
+		Dataset:         m.Dataset.fromHeaders(header, query),
+		ProxyToken:      m.ProxyToken.fromHeaders(header, credentialQuery),
+		ProxyVersion:    m.ProxyVersion.fromHeaders(header, query),
+		UserAgent:       m.UserAgent.fromHeaders(header, query),
+		ContentType:     m.ContentType.fromHeaders(header, query),
+		ContentEncoding: m.ContentEncoding.fromHeaders(header, query),
+		ApiToken:        m.ApiToken.fromHeaders(header, credentialQuery),
+		ApiTenantId:     m.ApiTenantId.fromHeaders(header, credentialQuery),
+	}
+	for _, name := range m.Extra {
+		if val := header.Get(name); val != "" {
+			ri.addExtra(name, val)
+		}
+	}
+	return ri
+}
+
+func (m HeaderMapping) requestInfoFromMetadata(ctx context.Context) RequestInfo {
+	ri := RequestInfo{
+		ContentType: "application/protobuf",
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ri.Dataset = m.Dataset.fromMetadata(md)
+		ri.ProxyToken = m.ProxyToken.fromMetadata(md)
+		ri.ProxyVersion = m.ProxyVersion.fromMetadata(md)
+		ri.UserAgent = m.UserAgent.fromMetadata(md)
+		ri.ContentEncoding = m.ContentEncoding.fromMetadata(md)
+		ri.ApiToken = m.ApiToken.fromMetadata(md)
+		ri.ApiTenantId = m.ApiTenantId.fromMetadata(md)
+		for _, name := range m.Extra {
+			if val := getValueFromMetadata(md, name); val != "" {
+				ri.addExtra(name, val)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ri.RemoteAddr = p.Addr.String()
+	}
+	return ri
+}
+
+// addExtra stores val under the lowercased name so HTTP and gRPC requests use the same keys
+func (ri *RequestInfo) addExtra(name, val string) {
+	if ri.Extra == nil {
+		ri.Extra = map[string]string{}
+	}
+	ri.Extra[strings.ToLower(name)] = val
+}
+
+// Request fields that WithRequestAttributes can copy onto events, extra headers are named by their lowercased header
+const (
+	RequestFieldUserAgent    = "userAgent"
+	RequestFieldProxyVersion = "proxyVersion"
+	RequestFieldRemoteAddr   = "remoteAddr"
+)
+
+// requestAttributes returns the allowlisted request values that are set, or nil when there are none
+// Credentials are never included
+func requestAttributes(ri RequestInfo, fields []string) map[string]interface{} {
+	var attrs map[string]interface{}
+	for _, field := range fields {
+		var val string
+		switch field {
+		case RequestFieldUserAgent:
+			val = ri.UserAgent
+		case RequestFieldProxyVersion:
+			val = ri.ProxyVersion
+		case RequestFieldRemoteAddr:
+			val = ri.RemoteAddr
+		default:
+			val = ri.Extra[strings.ToLower(field)]
+		}
+		if val == "" {
+			continue
+		}
+		if attrs == nil {
+			attrs = map[string]interface{}{}
+		}
+		attrs[field] = val
+	}
+	return attrs
+}
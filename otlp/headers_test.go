@@ -0,0 +1,136 @@
+package otlp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+var gatewayMapping = HeaderMapping{
+	Dataset:  HeaderNames{Headers: []string{"x-gateway-dataset", "x-opsramp-dataset"}, QueryParams: []string{"dataset"}},
+	ApiToken: HeaderNames{Headers: []string{"x-gateway-token"}, QueryParams: []string{"token", "api_key"}},
+	Extra:    []string{"X-Forwarded-For", "x-request-id"},
+}
+
+func TestGetRequestInfoFromHttpHeadersDefaultMapping(t *testing.T) {
+	header := http.Header{}
+	header.Set(datasetHeader, "test-dataset")
+	header.Set(apiTokenHeader, "test-token")
+	header.Set(apiTenantId, "test-tenant")
+	header.Set(contentTypeHeader, "application/protobuf")
+	header.Set("x-request-id", "abc")
+
+	ri := GetRequestInfoFromHttpHeaders(header)
+	assert.Equal(t, RequestInfo{
+		Dataset:     "test-dataset",
+		ContentType: "application/protobuf",
+		ApiToken:    "test-token",
+		ApiTenantId: "test-tenant",
+	}, ri)
+}
+
+func TestGetRequestInfoFromHttpHeadersAliases(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-opsramp-dataset", "fallback-dataset")
+	header.Set("x-gateway-token", "gateway-token")
+	header.Set(apiTenantId, "test-tenant")
+	header.Set("x-forwarded-for", "10.0.0.1")
+
+	ri := GetRequestInfoFromHttpHeaders(header, WithHeaderMapping(gatewayMapping))
+	assert.Equal(t, "fallback-dataset", ri.Dataset)
+	assert.Equal(t, "gateway-token", ri.ApiToken)
+	// unmapped fields keep the default names
+	assert.Equal(t, "test-tenant", ri.ApiTenantId)
+	assert.Equal(t, map[string]string{"x-forwarded-for": "10.0.0.1"}, ri.Extra)
+
+	header.Set("x-gateway-dataset", "gateway-dataset")
+	ri = GetRequestInfoFromHttpHeaders(header, WithHeaderMapping(gatewayMapping))
+	assert.Equal(t, "gateway-dataset", ri.Dataset)
+}
+
+func TestGetRequestInfoFromHttpRequestQueryParams(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/traces?dataset=query-dataset&api_key=query-token", nil)
+	r.Header.Set("x-request-id", "abc")
+
+	ri := GetRequestInfoFromHttpRequest(r, WithHeaderMapping(gatewayMapping))
+	assert.Equal(t, "query-dataset", ri.Dataset)
+	assert.Equal(t, map[string]string{"x-request-id": "abc"}, ri.Extra)
+	// credentials are only read from the URL when explicitly allowed
+	assert.Equal(t, "", ri.ApiToken)
+
+	mapping := gatewayMapping
+	mapping.AllowCredentialQueryParams = true
+	ri = GetRequestInfoFromHttpRequest(r, WithHeaderMapping(mapping))
+	assert.Equal(t, "query-token", ri.ApiToken)
+
+	// headers win over query parameters
+	r.Header.Set("x-gateway-token", "header-token")
+	ri = GetRequestInfoFromHttpRequest(r, WithHeaderMapping(mapping))
+	assert.Equal(t, "header-token", ri.ApiToken)
+
+	// query parameters are only used when configured
+	ri = GetRequestInfoFromHttpRequest(r)
+	assert.Equal(t, "", ri.Dataset)
+}
+
+func TestGetRequestInfoFromGrpcMetadataAliases(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		"x-gateway-dataset": "gateway-dataset",
+		"x-gateway-token":   "gateway-token",
+		"x-forwarded-for":   "10.0.0.1",
+		"x-request-id":      "abc",
+	}))
+
+	ri := GetRequestInfoFromGrpcMetadata(ctx, WithHeaderMapping(gatewayMapping))
+	assert.Equal(t, "gateway-dataset", ri.Dataset)
+	assert.Equal(t, "gateway-token", ri.ApiToken)
+	assert.Equal(t, "application/protobuf", ri.ContentType)
+	assert.Equal(t, map[string]string{"x-forwarded-for": "10.0.0.1", "x-request-id": "abc"}, ri.Extra)
+
+	ri = GetRequestInfoFromGrpcMetadata(ctx)
+	assert.Equal(t, "", ri.Dataset)
+	assert.Nil(t, ri.Extra)
+}
+
+func TestRemoteAddr(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/traces", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "10.0.0.1:1234", GetRequestInfoFromHttpRequest(r).RemoteAddr)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4317}})
+	assert.Equal(t, "10.0.0.2:4317", GetRequestInfoFromGrpcMetadata(ctx).RemoteAddr)
+}
+
+func TestTranslateTraceReqRequestAttributes(t *testing.T) {
+	request := newTestRequest(newTestSpan("a"), newTestSpan("b"))
+	r := httptest.NewRequest(http.MethodPost, "/v1/traces", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set(userAgentHeader, "otel-go/1.0")
+	r.Header.Set(apiTokenHeader, "secret")
+	r.Header.Set("X-Forwarded-For", "192.168.0.1")
+	ri := GetRequestInfoFromHttpRequest(r, WithHeaderMapping(HeaderMapping{Extra: []string{"x-forwarded-for"}}))
+
+	result, err := TranslateTraceReq(request, ri, WithRequestAttributes(RequestFieldUserAgent, RequestFieldRemoteAddr, RequestFieldProxyVersion, "X-Forwarded-For", "authorization"))
+	require.NoError(t, err)
+	for _, ev := range result.Batches[0].Events {
+		assert.Equal(t, map[string]interface{}{
+			"userAgent":       "otel-go/1.0",
+			"remoteAddr":      "10.0.0.1:1234",
+			"X-Forwarded-For": "192.168.0.1",
+		}, ev.Attributes["requestAttributes"])
+	}
+	// events do not share the map
+	result.Batches[0].Events[0].Attributes["requestAttributes"].(map[string]interface{})["userAgent"] = "changed"
+	assert.Equal(t, "otel-go/1.0", result.Batches[0].Events[1].Attributes["requestAttributes"].(map[string]interface{})["userAgent"])
+
+	result, err = TranslateTraceReq(request, ri)
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "requestAttributes")
+}
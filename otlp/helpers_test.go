@@ -0,0 +1,37 @@
+package otlp
+
+import (
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// newTestSpan returns a valid one millisecond span with random IDs
+func newTestSpan(name string) *trace.Span {
+	start := time.Now()
+	return &trace.Span{
+		TraceId:           test.RandomBytes(16),
+		SpanId:            test.RandomBytes(8),
+		Name:              name,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(start.Add(time.Millisecond).UnixNano()),
+	}
+}
+
+// newTestRequest wraps spans in a request with a single resource and library
+func newTestRequest(spans ...*trace.Span) *collectortrace.ExportTraceServiceRequest {
+	return &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{
+			Resource:   &resource.Resource{},
+			ScopeSpans: []*trace.ScopeSpans{{Spans: spans}},
+		}},
+	}
+}
+
+func stringAttr(key string, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
@@ -0,0 +1,247 @@
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	signalTraces = "traces"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+
+	eventKindSpan      = "span"
+	eventKindSpanEvent = "span_event"
+	eventKindLink      = "link"
+
+	dropReasonInvalid  = "invalid"
+	dropReasonSDKLimit = "sdk_limit"
+)
+
+// MetricsRecorder receives self-telemetry from the translators
+// signal is the OTLP signal being translated, eg "traces"
+type MetricsRecorder interface {
+	// RecordRequest counts a translated request, result is either "success" or "failure"
+	RecordRequest(signal string, result string)
+	// RecordBytesReceived counts request body bytes before and after decompression
+	RecordBytesReceived(signal string, compressed int, decompressed int)
+	// RecordItems counts items translated, kind is "span", "span_event" or "link"
+	// Spans rejected by validation or dropped by processors are not counted
+	RecordItems(signal string, kind string, count int)
+	// RecordAttributesDropped counts attributes lost, reason is "invalid" when the translator
+	// ignored them and "sdk_limit" when the sender reported dropping them
+	RecordAttributesDropped(signal string, reason string, count int)
+	// RecordAttributesTruncated counts attribute values cut to the maximum field size
+	RecordAttributesTruncated(signal string, count int)
+	// RecordParseFailure counts requests that could not be parsed by the OTLPError returned
+	RecordParseFailure(signal string, errorType string)
+	// RecordTranslationLatency observes the time taken to translate a request
+	RecordTranslationLatency(signal string, duration time.Duration)
+}
+
+// NoopMetricsRecorder discards all metrics, it is the default recorder
+type NoopMetricsRecorder struct{}
+
+func (NoopMetricsRecorder) RecordRequest(string, string)                   {}
+func (NoopMetricsRecorder) RecordBytesReceived(string, int, int)           {}
+func (NoopMetricsRecorder) RecordItems(string, string, int)                {}
+func (NoopMetricsRecorder) RecordAttributesDropped(string, string, int)    {}
+func (NoopMetricsRecorder) RecordAttributesTruncated(string, int)          {}
+func (NoopMetricsRecorder) RecordParseFailure(string, string)              {}
+func (NoopMetricsRecorder) RecordTranslationLatency(string, time.Duration) {}
+
+// errorTypeLabel returns a stable label for the known OTLPErrors
+func errorTypeLabel(err error) string {
+	var otlpErr OTLPError
+	if !errors.As(err, &otlpErr) {
+		return "unknown"
+	}
+	switch otlpErr.Message {
+	case ErrFailedParseBody.Message:
+		return "failed_parse_body"
+	case ErrInvalidContentType.Message:
+		return "invalid_content_type"
+	case ErrMissingDatasetHeader.Message:
+		return "missing_dataset_header"
+	}
+	return "unknown"
+}
+
+var defaultLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// PrometheusMetricsRecorder keeps metrics in memory and serves them in the Prometheus
+// text exposition format, so it can be mounted directly as a /metrics http.Handler
+type PrometheusMetricsRecorder struct {
+	namespace  string
+	mu         sync.Mutex
+	counters   map[string]*promFamily
+	histograms map[string]*promHistogram
+}
+
+type promFamily struct {
+	help   string
+	values map[string]float64
+}
+
+type promHistogram struct {
+	help    string
+	buckets []float64
+	series  map[string]*promHistogramSeries
+}
+
+type promHistogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetricsRecorder creates a recorder whose metric names are prefixed by namespace
+func NewPrometheusMetricsRecorder(namespace string) *PrometheusMetricsRecorder {
+	if namespace == "" {
+		namespace = "husky"
+	}
+	return &PrometheusMetricsRecorder{
+		namespace:  namespace,
+		counters:   make(map[string]*promFamily),
+		histograms: make(map[string]*promHistogram),
+	}
+}
+
+func (p *PrometheusMetricsRecorder) RecordRequest(signal string, result string) {
+	p.add("translate_requests_total", "Requests translated by signal and result.", 1, "signal", signal, "result", result)
+}
+
+func (p *PrometheusMetricsRecorder) RecordBytesReceived(signal string, compressed int, decompressed int) {
+	const help = "Request body bytes received, before and after decompression."
+	p.add("translate_received_bytes_total", help, float64(compressed), "signal", signal, "encoding", "compressed")
+	p.add("translate_received_bytes_total", help, float64(decompressed), "signal", signal, "encoding", "decompressed")
+}
+
+func (p *PrometheusMetricsRecorder) RecordItems(signal string, kind string, count int) {
+	p.add("translate_items_total", "Spans, span events and links translated.", float64(count), "signal", signal, "kind", kind)
+}
+
+func (p *PrometheusMetricsRecorder) RecordAttributesDropped(signal string, reason string, count int) {
+	p.add("translate_attributes_dropped_total", "Attributes dropped by the translator or reported dropped by the sender.", float64(count), "signal", signal, "reason", reason)
+}
+
+func (p *PrometheusMetricsRecorder) RecordAttributesTruncated(signal string, count int) {
+	p.add("translate_attributes_truncated_total", "Attribute values truncated to the maximum field size.", float64(count), "signal", signal)
+}
+
+func (p *PrometheusMetricsRecorder) RecordParseFailure(signal string, errorType string) {
+	p.add("translate_parse_failures_total", "Requests that failed to parse by error type.", 1, "signal", signal, "error_type", errorType)
+}
+
+func (p *PrometheusMetricsRecorder) RecordTranslationLatency(signal string, duration time.Duration) {
+	name := p.namespace + "_translate_duration_seconds"
+	labels := formatPromLabels("signal", signal)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	histogram, ok := p.histograms[name]
+	if !ok {
+		histogram = &promHistogram{
+			help:    "Time taken to translate a request.",
+			buckets: defaultLatencyBuckets,
+			series:  make(map[string]*promHistogramSeries),
+		}
+		p.histograms[name] = histogram
+	}
+	series, ok := histogram.series[labels]
+	if !ok {
+		series = &promHistogramSeries{counts: make([]uint64, len(histogram.buckets))}
+		histogram.series[labels] = series
+	}
+	seconds := duration.Seconds()
+	for i, bound := range histogram.buckets {
+		if seconds <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += seconds
+}
+
+// add increments a counter series, creating it even when delta is zero so every series is exposed once seen
+func (p *PrometheusMetricsRecorder) add(name string, help string, delta float64, labelPairs ...string) {
+	name = p.namespace + "_" + name
+	labels := formatPromLabels(labelPairs...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	family, ok := p.counters[name]
+	if !ok {
+		family = &promFamily{help: help, values: make(map[string]float64)}
+		p.counters[name] = family
+	}
+	family.values[labels] += delta
+}
+
+// ServeHTTP writes all recorded metrics in the Prometheus text exposition format
+func (p *PrometheusMetricsRecorder) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var sb strings.Builder
+	p.mu.Lock()
+	for _, name := range sortedKeys(p.counters) {
+		family := p.counters[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s counter\n", name, family.help, name)
+		for _, labels := range sortedKeys(family.values) {
+			fmt.Fprintf(&sb, "%s{%s} %s\n", name, labels, formatPromValue(family.values[labels]))
+		}
+	}
+	for _, name := range sortedKeys(p.histograms) {
+		histogram := p.histograms[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s histogram\n", name, histogram.help, name)
+		for _, labels := range sortedKeys(histogram.series) {
+			series := histogram.series[labels]
+			for i, bound := range histogram.buckets {
+				fmt.Fprintf(&sb, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatPromValue(bound), series.counts[i])
+			}
+			fmt.Fprintf(&sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, series.count)
+			fmt.Fprintf(&sb, "%s_sum{%s} %s\n", name, labels, formatPromValue(series.sum))
+			fmt.Fprintf(&sb, "%s_count{%s} %d\n", name, labels, series.count)
+		}
+	}
+	p.mu.Unlock()
+
+	w.Write([]byte(sb.String()))
+}
+
+// formatPromLabels renders name/value pairs as a Prometheus label set, without the braces
+func formatPromLabels(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(promLabelEscaper.Replace(pairs[i+1]))
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatPromValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
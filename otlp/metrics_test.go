@@ -0,0 +1,109 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func newMetricsTestRequest() *collectortrace.ExportTraceServiceRequest {
+	span := newTestSpan("test_span")
+	span.Attributes = []*common.KeyValue{
+		stringAttr("span_attr", "val"),
+		stringAttr("long_attr", strings.Repeat("a", fieldSizeMax+1)),
+		{Key: "", Value: nil},
+	}
+	span.DroppedAttributesCount = 3
+	span.Events = []*trace.Span_Event{{Name: "event"}}
+	span.Links = []*trace.Span_Link{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}}
+	req := newTestRequest(span)
+	req.ResourceSpans[0].Resource.Attributes = []*common.KeyValue{stringAttr("service.name", "my-service")}
+	req.ResourceSpans[0].Resource.DroppedAttributesCount = 2
+	return req
+}
+
+func TestTranslateTraceReqRecordsMetrics(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("")
+	req := newMetricsTestRequest()
+	size := strconv.Itoa(proto.Size(req))
+	_, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"}, WithMetricsRecorder(recorder))
+	assert.Nil(t, err)
+
+	output := scrapeMetrics(recorder)
+	assert.Contains(t, output, "# TYPE husky_translate_requests_total counter\n")
+	assert.Contains(t, output, `husky_translate_requests_total{signal="traces",result="success"} 1`)
+	assert.Contains(t, output, `husky_translate_items_total{signal="traces",kind="span"} 1`)
+	assert.Contains(t, output, `husky_translate_items_total{signal="traces",kind="span_event"} 1`)
+	assert.Contains(t, output, `husky_translate_items_total{signal="traces",kind="link"} 1`)
+	assert.Contains(t, output, `husky_translate_attributes_dropped_total{signal="traces",reason="invalid"} 1`)
+	assert.Contains(t, output, `husky_translate_attributes_dropped_total{signal="traces",reason="sdk_limit"} 5`)
+	assert.Contains(t, output, `husky_translate_attributes_truncated_total{signal="traces"} 1`)
+	// gRPC requests arrive decoded, so both byte counts are the size of the request
+	assert.Contains(t, output, `husky_translate_received_bytes_total{signal="traces",encoding="compressed"} `+size+"\n")
+	assert.Contains(t, output, `husky_translate_received_bytes_total{signal="traces",encoding="decompressed"} `+size+"\n")
+	assert.Contains(t, output, "# TYPE husky_translate_duration_seconds histogram\n")
+	assert.Contains(t, output, `husky_translate_duration_seconds_count{signal="traces"} 1`)
+	assert.Contains(t, output, `husky_translate_duration_seconds_bucket{signal="traces",le="+Inf"} 1`)
+}
+
+func TestTranslateTraceReqFromReaderRecordsMetrics(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("ingest")
+	body, err := proto.Marshal(newMetricsTestRequest())
+	assert.Nil(t, err)
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(body)
+	writer.Close()
+
+	ri := RequestInfo{Dataset: "dataset", ContentType: "application/protobuf", ContentEncoding: "gzip"}
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(compressed.Bytes())), ri, WithMetricsRecorder(recorder))
+	assert.Nil(t, err)
+
+	// bytes are counted even when the body cannot be parsed
+	invalid := []byte("not-a-proto")
+	ri = RequestInfo{ContentType: "application/protobuf"}
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(invalid)), ri, WithMetricsRecorder(recorder))
+	assert.Equal(t, ErrFailedParseBody, err)
+
+	ri = RequestInfo{ContentType: "application/json"}
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(body)), ri, WithMetricsRecorder(recorder))
+	assert.Equal(t, ErrInvalidContentType, err)
+
+	output := scrapeMetrics(recorder)
+	assert.Contains(t, output, `ingest_translate_requests_total{signal="traces",result="success"} 1`)
+	assert.Contains(t, output, `ingest_translate_requests_total{signal="traces",result="failure"} 2`)
+	assert.Contains(t, output, `ingest_translate_parse_failures_total{signal="traces",error_type="failed_parse_body"} 1`)
+	assert.Contains(t, output, `ingest_translate_parse_failures_total{signal="traces",error_type="invalid_content_type"} 1`)
+	assert.Contains(t, output, `ingest_translate_received_bytes_total{signal="traces",encoding="compressed"} `+strconv.Itoa(compressed.Len()+len(invalid))+"\n")
+	assert.Contains(t, output, `ingest_translate_received_bytes_total{signal="traces",encoding="decompressed"} `+strconv.Itoa(len(body)+len(invalid))+"\n")
+}
+
+func TestPrometheusLabelValuesAreEscaped(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("")
+	recorder.RecordParseFailure("traces", "quote\"back\\slash\nnewline")
+	assert.Contains(t, scrapeMetrics(recorder), `error_type="quote\"back\\slash\nnewline"} 1`)
+}
+
+func TestPrometheusZeroCountsAreExposed(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("")
+	recorder.RecordItems("traces", "link", 0)
+	assert.Contains(t, scrapeMetrics(recorder), `husky_translate_items_total{signal="traces",kind="link"} 0`)
+}
+
+func scrapeMetrics(recorder *PrometheusMetricsRecorder) string {
+	w := httptest.NewRecorder()
+	recorder.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
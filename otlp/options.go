@@ -0,0 +1,105 @@
+package otlp
+
+import "context"
+
+// TranslateOption configures optional behaviour of the translators
+type TranslateOption func(*translateConfig)
+
+type translateConfig struct {
+	metrics           MetricsRecorder
+	invalidSpanPolicy InvalidSpanPolicy
+	requestFields     []string
+	transforms        *TransformChain
+	processors        []Processor
+	semconvFields     bool
+	schemaMigrator    *SchemaMigrator
+	errorOnException  bool
+	stacktraceFrames  bool
+	ctx               context.Context
+}
+
+func newTranslateConfig(opts []TranslateOption) *translateConfig {
+	cfg := &translateConfig{
+		metrics: NoopMetricsRecorder{},
+		ctx:     context.Background(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithMetricsRecorder records self-telemetry about the translation to the given recorder
+func WithMetricsRecorder(recorder MetricsRecorder) TranslateOption {
+	return func(cfg *translateConfig) {
+		if recorder != nil {
+			cfg.metrics = recorder
+		}
+	}
+}
+
+// WithInvalidSpanPolicy sets what happens to spans with invalid IDs or timestamps, by default they are kept
+func WithInvalidSpanPolicy(policy InvalidSpanPolicy) TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.invalidSpanPolicy = policy
+	}
+}
+
+// WithRequestAttributes copies the named RequestInfo values onto every event as requestAttributes
+// Fields are RequestFieldUserAgent, RequestFieldProxyVersion, RequestFieldRemoteAddr or headers captured in RequestInfo.Extra
+func WithRequestAttributes(fields ...string) TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.requestFields = append(cfg.requestFields, fields...)
+	}
+}
+
+// WithTransforms applies the transform chain to every event after it is translated
+func WithTransforms(chain *TransformChain) TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.transforms = chain
+	}
+}
+
+// WithProcessors runs the processors over the translated batches, in the order given
+func WithProcessors(processors ...Processor) TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.processors = append(cfg.processors, processors...)
+	}
+}
+
+// WithContext sets the context passed to processors, translation stops with its error once it is done
+func WithContext(ctx context.Context) TranslateOption {
+	return func(cfg *translateConfig) {
+		if ctx != nil {
+			cfg.ctx = ctx
+		}
+	}
+}
+
+// WithSemconvFields adds the fields derived by AddSemconvFields to every span
+func WithSemconvFields() TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.semconvFields = true
+	}
+}
+
+// WithSchemaMigrator renames attributes to the migrator's target semantic convention version
+func WithSchemaMigrator(migrator *SchemaMigrator) TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.schemaMigrator = migrator
+	}
+}
+
+// WithErrorOnException sets error on spans with an exception event when their status is unset
+func WithErrorOnException() TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.errorOnException = true
+	}
+}
+
+// WithStacktraceFrames adds the frames parsed from exception stack traces to spans, see AddStacktraceFields
+func WithStacktraceFrames() TranslateOption {
+	return func(cfg *translateConfig) {
+		cfg.stacktraceFrames = true
+	}
+}
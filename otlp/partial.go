@@ -0,0 +1,77 @@
+package otlp
+
+import (
+	"strings"
+
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorMetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// PartialSuccess counts the items of a request that were accepted and rejected, items are spans,
+// metric data points or log records depending on the signal. Warnings explain rejected items or
+// anything else the client should know about, even when every item was accepted
+type PartialSuccess struct {
+	Accepted int
+	Rejected int
+	Warnings []string
+}
+
+// IsPartial returns true if the response should carry a partial success, as required by the
+// OTLP spec when items were rejected or there are warnings for the client
+func (p PartialSuccess) IsPartial() bool {
+	return p.Rejected > 0 || len(p.Warnings) > 0
+}
+
+// ErrorMessage returns the warnings as a single message for the partial success response
+func (p PartialSuccess) ErrorMessage() string {
+	return strings.Join(p.Warnings, "; ")
+}
+
+// PartialSuccess returns the spans accepted and rejected while translating the request
+func (r *TranslateTraceRequestResult) PartialSuccess() PartialSuccess {
+	return PartialSuccess{
+		Accepted: r.AcceptedSpans,
+		Rejected: r.RejectedSpans,
+		Warnings: r.Warnings,
+	}
+}
+
+// NewExportTraceServiceResponse builds the response to an OTLP trace export
+// PartialSuccess is left unset when the request was fully accepted
+func NewExportTraceServiceResponse(p PartialSuccess) *collectorTrace.ExportTraceServiceResponse {
+	response := &collectorTrace.ExportTraceServiceResponse{}
+	if p.IsPartial() {
+		response.PartialSuccess = &collectorTrace.ExportTracePartialSuccess{
+			RejectedSpans: int64(p.Rejected),
+			ErrorMessage:  p.ErrorMessage(),
+		}
+	}
+	return response
+}
+
+// NewExportMetricsServiceResponse builds the response to an OTLP metrics export
+// PartialSuccess is left unset when the request was fully accepted
+func NewExportMetricsServiceResponse(p PartialSuccess) *collectorMetrics.ExportMetricsServiceResponse {
+	response := &collectorMetrics.ExportMetricsServiceResponse{}
+	if p.IsPartial() {
+		response.PartialSuccess = &collectorMetrics.ExportMetricsPartialSuccess{
+			RejectedDataPoints: int64(p.Rejected),
+			ErrorMessage:       p.ErrorMessage(),
+		}
+	}
+	return response
+}
+
+// NewExportLogsServiceResponse builds the response to an OTLP logs export
+// PartialSuccess is left unset when the request was fully accepted
+func NewExportLogsServiceResponse(p PartialSuccess) *collectorLogs.ExportLogsServiceResponse {
+	response := &collectorLogs.ExportLogsServiceResponse{}
+	if p.IsPartial() {
+		response.PartialSuccess = &collectorLogs.ExportLogsPartialSuccess{
+			RejectedLogRecords: int64(p.Rejected),
+			ErrorMessage:       p.ErrorMessage(),
+		}
+	}
+	return response
+}
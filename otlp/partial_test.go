@@ -0,0 +1,68 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestTranslateTraceReqPartialSuccess(t *testing.T) {
+	request := newInvalidSpansRequest()
+	request.ResourceSpans[0].ScopeSpans[0].Spans[0].Attributes = []*common.KeyValue{{Key: ""}}
+
+	result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"}, WithInvalidSpanPolicy(InvalidSpanFix))
+	require.NoError(t, err)
+	assert.Equal(t, PartialSuccess{
+		Accepted: 2,
+		Rejected: 1,
+		Warnings: []string{"1 spans rejected: invalid_span_id", "1 attributes dropped: missing key or value"},
+	}, result.PartialSuccess())
+
+	response := NewExportTraceServiceResponse(result.PartialSuccess())
+	require.NotNil(t, response.PartialSuccess)
+	assert.Equal(t, int64(1), response.PartialSuccess.RejectedSpans)
+	assert.Equal(t, "1 spans rejected: invalid_span_id; 1 attributes dropped: missing key or value", response.PartialSuccess.ErrorMessage)
+}
+
+func TestTranslateTraceReqPartialSuccessKeptSpans(t *testing.T) {
+	result, err := TranslateTraceReq(newInvalidSpansRequest(), RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.AcceptedSpans)
+	assert.Equal(t, 0, result.RejectedSpans)
+	assert.Equal(t, []string{"1 invalid spans accepted: end_before_start", "1 invalid spans accepted: invalid_span_id"}, result.Warnings)
+
+	response := NewExportTraceServiceResponse(result.PartialSuccess())
+	require.NotNil(t, response.PartialSuccess)
+	assert.Equal(t, int64(0), response.PartialSuccess.RejectedSpans)
+}
+
+func TestNewExportServiceResponsesFullSuccess(t *testing.T) {
+	request := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{newTestSpan("span")}}}}},
+	}
+	result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	assert.Equal(t, PartialSuccess{Accepted: 1}, result.PartialSuccess())
+
+	assert.Nil(t, NewExportTraceServiceResponse(result.PartialSuccess()).PartialSuccess)
+	assert.Nil(t, NewExportMetricsServiceResponse(PartialSuccess{Accepted: 10}).PartialSuccess)
+	assert.Nil(t, NewExportLogsServiceResponse(PartialSuccess{Accepted: 10}).PartialSuccess)
+}
+
+func TestNewExportMetricsAndLogsServiceResponses(t *testing.T) {
+	p := PartialSuccess{Accepted: 8, Rejected: 2, Warnings: []string{"2 data points rejected: missing name"}}
+
+	metrics := NewExportMetricsServiceResponse(p)
+	require.NotNil(t, metrics.PartialSuccess)
+	assert.Equal(t, int64(2), metrics.PartialSuccess.RejectedDataPoints)
+	assert.Equal(t, "2 data points rejected: missing name", metrics.PartialSuccess.ErrorMessage)
+
+	logs := NewExportLogsServiceResponse(p)
+	require.NotNil(t, logs.PartialSuccess)
+	assert.Equal(t, int64(2), logs.PartialSuccess.RejectedLogRecords)
+	assert.Equal(t, "2 data points rejected: missing name", logs.PartialSuccess.ErrorMessage)
+}
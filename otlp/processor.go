@@ -0,0 +1,82 @@
+package otlp
+
+import "context"
+
+// rejectReasonProcessor is the reason reported for spans whose events a processor dropped
+const rejectReasonProcessor = "dropped_by_processor"
+
+// Processor is a hook that runs between translation and output
+// Processors run one at a time in the order they were given, events are processed in batch order
+// and every event of a batch goes through all processors before ProcessBatch is called
+type Processor interface {
+	// ProcessEvent returns the events that replace ev, returning none drops it
+	ProcessEvent(ctx context.Context, ri RequestInfo, ev Event) ([]Event, error)
+	// ProcessBatch is called once per batch after its events have been processed
+	ProcessBatch(ctx context.Context, ri RequestInfo, batch *Batch) error
+}
+
+// EventProcessorFunc adapts a function to a Processor that only processes events
+type EventProcessorFunc func(ctx context.Context, ri RequestInfo, ev Event) ([]Event, error)
+
+func (f EventProcessorFunc) ProcessEvent(ctx context.Context, ri RequestInfo, ev Event) ([]Event, error) {
+	return f(ctx, ri, ev)
+}
+
+func (f EventProcessorFunc) ProcessBatch(context.Context, RequestInfo, *Batch) error {
+	return nil
+}
+
+// RunProcessors passes the batches through the processors, modifying them in place
+// It returns the number of events dropped, an event is dropped when no event a processor returned
+// in its place, or in place of those, is left. It stops at the first error returned by a processor
+// or when ctx is done
+func RunProcessors(ctx context.Context, ri RequestInfo, batches []Batch, processors ...Processor) (int, error) {
+	if len(processors) == 0 {
+		return 0, nil
+	}
+	dropped := 0
+	for i := range batches {
+		batch := &batches[i]
+		events := batch.Events
+		// origins holds the index of the translated event each event came from
+		origins := make([]int, len(events))
+		for j := range origins {
+			origins[j] = j
+		}
+		for _, processor := range processors {
+			var processed []Event
+			var processedOrigins []int
+			for j, ev := range events {
+				if err := ctx.Err(); err != nil {
+					return dropped, err
+				}
+				out, err := processor.ProcessEvent(ctx, ri, ev)
+				if err != nil {
+					return dropped, err
+				}
+				processed = append(processed, out...)
+				for range out {
+					processedOrigins = append(processedOrigins, origins[j])
+				}
+			}
+			events, origins = processed, processedOrigins
+		}
+
+		kept := make(map[int]bool, len(origins))
+		for _, origin := range origins {
+			kept[origin] = true
+		}
+		dropped += len(batch.Events) - len(kept)
+		batch.Events = events
+
+		for _, processor := range processors {
+			if err := ctx.Err(); err != nil {
+				return dropped, err
+			}
+			if err := processor.ProcessBatch(ctx, ri, batch); err != nil {
+				return dropped, err
+			}
+		}
+	}
+	return dropped, nil
+}
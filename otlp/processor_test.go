@@ -0,0 +1,142 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	name  string
+	calls *[]string
+}
+
+func (p recordingProcessor) ProcessEvent(_ context.Context, _ RequestInfo, ev Event) ([]Event, error) {
+	*p.calls = append(*p.calls, p.name+":"+ev.Attributes["spanName"].(string))
+	return []Event{ev}, nil
+}
+
+func (p recordingProcessor) ProcessBatch(_ context.Context, _ RequestInfo, batch *Batch) error {
+	*p.calls = append(*p.calls, p.name+":batch:"+batch.Dataset)
+	return nil
+}
+
+func TestTranslateTraceReqProcessorOrder(t *testing.T) {
+	var calls []string
+	request := newTestRequest(newTestSpan("a"), newTestSpan("b"), newTestSpan("c"))
+	result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"}, WithProcessors(
+		recordingProcessor{name: "first", calls: &calls},
+		recordingProcessor{name: "second", calls: &calls},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(result.Batches[0].Events))
+	assert.Equal(t, []string{
+		"first:a", "first:b", "first:c",
+		"second:a", "second:b", "second:c",
+		"first:batch:dataset", "second:batch:dataset",
+	}, calls)
+}
+
+func TestTranslateTraceReqProcessorDropsAndAddsEvents(t *testing.T) {
+	versions := map[string]string{"dataset": "v1.2.3"}
+	processor := EventProcessorFunc(func(_ context.Context, ri RequestInfo, ev Event) ([]Event, error) {
+		switch ev.Attributes["spanName"] {
+		case "a":
+			return nil, nil
+		case "b":
+			extra := Event{Attributes: map[string]interface{}{"spanName": "b.extra"}}
+			return []Event{ev, extra}, nil
+		}
+		ev.Attributes["deployment.version"] = versions[ri.Dataset]
+		return []Event{ev}, nil
+	})
+
+	request := newTestRequest(newTestSpan("a"), newTestSpan("b"), newTestSpan("c"))
+	result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"}, WithProcessors(processor))
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 3, len(events))
+	assert.Equal(t, "b", events[0].Attributes["spanName"])
+	assert.Equal(t, "b.extra", events[1].Attributes["spanName"])
+	assert.Equal(t, "c", events[2].Attributes["spanName"])
+	assert.Equal(t, "v1.2.3", events[2].Attributes["deployment.version"])
+}
+
+func TestTranslateTraceReqProcessorError(t *testing.T) {
+	processorErr := errors.New("lookup failed")
+	var calls int
+	processor := EventProcessorFunc(func(_ context.Context, _ RequestInfo, ev Event) ([]Event, error) {
+		calls++
+		return nil, processorErr
+	})
+
+	request := newTestRequest(newTestSpan("a"), newTestSpan("b"), newTestSpan("c"))
+	result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"}, WithProcessors(processor))
+	assert.Nil(t, result)
+	assert.Equal(t, processorErr, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTranslateTraceReqProcessorContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	processor := EventProcessorFunc(func(_ context.Context, _ RequestInfo, ev Event) ([]Event, error) {
+		calls++
+		cancel()
+		return []Event{ev}, nil
+	})
+
+	request := newTestRequest(newTestSpan("a"), newTestSpan("b"), newTestSpan("c"))
+	_, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"}, WithContext(ctx), WithProcessors(processor))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTranslateTraceReqProcessorDropsCountAsRejected(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("")
+	dropValid := EventProcessorFunc(func(_ context.Context, _ RequestInfo, ev Event) ([]Event, error) {
+		if ev.Attributes["spanName"] == "valid" {
+			return nil, nil
+		}
+		return []Event{ev}, nil
+	})
+
+	result, err := TranslateTraceReq(newInvalidSpansRequest(), RequestInfo{Dataset: "dataset"},
+		WithInvalidSpanPolicy(InvalidSpanFix), WithProcessors(dropValid), WithMetricsRecorder(recorder))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches[0].Events))
+	assert.Equal(t, 1, result.AcceptedSpans)
+	assert.Equal(t, 2, result.RejectedSpans)
+	assert.Equal(t, []string{
+		"1 spans rejected: dropped_by_processor",
+		"1 spans rejected: invalid_span_id",
+	}, result.Warnings)
+	assert.Contains(t, scrapeMetrics(recorder), `husky_translate_items_total{signal="traces",kind="span"} 1`)
+}
+
+func TestRunProcessorsDroppedCount(t *testing.T) {
+	split := EventProcessorFunc(func(_ context.Context, _ RequestInfo, ev Event) ([]Event, error) {
+		extra := Event{Attributes: map[string]interface{}{"spanName": ev.Attributes["spanName"].(string) + ".extra"}}
+		return []Event{ev, extra}, nil
+	})
+	dropOriginals := EventProcessorFunc(func(_ context.Context, _ RequestInfo, ev Event) ([]Event, error) {
+		if ev.Attributes["spanName"] == "a" || ev.Attributes["spanName"] == "b" || ev.Attributes["spanName"] == "b.extra" {
+			return nil, nil
+		}
+		return []Event{ev}, nil
+	})
+	batches := []Batch{{Events: []Event{
+		{Attributes: map[string]interface{}{"spanName": "a"}},
+		{Attributes: map[string]interface{}{"spanName": "b"}},
+		{Attributes: map[string]interface{}{"spanName": "c"}},
+	}}}
+
+	dropped, err := RunProcessors(context.Background(), RequestInfo{}, batches, split, dropOriginals)
+	require.NoError(t, err)
+	// a is kept through a.extra, b and everything added for it were dropped
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 3, len(batches[0].Events))
+}
@@ -0,0 +1,212 @@
+package otlp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const maxRateLimitBuckets = 10000
+
+// RateLimit describes token bucket limits, each expressed per second
+// A zero value disables the corresponding limit
+// BurstSeconds is how many seconds worth of tokens a bucket can hold, defaulting to one second
+type RateLimit struct {
+	RequestsPerSecond float64
+	SpansPerSecond    float64
+	BytesPerSecond    float64
+	BurstSeconds      float64
+}
+
+// RateLimiterConfig holds the default limits applied per tenant and per dataset
+// Overrides replace the default limits for individual tenant IDs or dataset names
+type RateLimiterConfig struct {
+	Tenant           RateLimit
+	Dataset          RateLimit
+	TenantOverrides  map[string]RateLimit
+	DatasetOverrides map[string]RateLimit
+}
+
+// RateLimiter applies token bucket rate limits keyed by RequestInfo.ApiTenantId and by dataset
+type RateLimiter struct {
+	config  RateLimiterConfig
+	now     func() time.Time
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter creates a rate limiter from the given configuration
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	return &RateLimiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+type rateLimitCost struct {
+	key        string
+	scope      string
+	rate       float64
+	burst      float64
+	tokens     float64
+	bucketName string
+}
+
+// AllowTraceRequest checks a translated trace request against the tenant and dataset limits
+// Requests and bytes (RequestSize) are charged to the tenant, while each batch charges its
+// spans and SizeBytes to its dataset. Either everything is charged or nothing is, and a
+// rejected request returns ErrRateLimited with RetryAfter set to when it could be admitted,
+// wrapping an error naming the tenant or dataset limit that was exceeded
+// Span and byte counts come from the translated result, so limits are only checked after the
+// whole request has been decoded and translated; reject oversized bodies before decoding them
+func (l *RateLimiter) AllowTraceRequest(ri RequestInfo, result *TranslateTraceRequestResult) error {
+	tenantLimit := l.limitFor(l.config.Tenant, l.config.TenantOverrides, ri.ApiTenantId)
+	spans := 0
+	for _, batch := range result.Batches {
+		spans += len(batch.Events)
+	}
+
+	var costs []rateLimitCost
+	tenantKey := "tenant\x00" + ri.ApiTenantId
+	tenantScope := fmt.Sprintf("tenant '%s'", ri.ApiTenantId)
+	costs = appendRateLimitCosts(costs, tenantKey, tenantScope, tenantLimit, 1, spans, result.RequestSize)
+
+	// merge batches that share a dataset so a request is charged once per dataset
+	datasetSpans := make(map[string]int)
+	datasetBytes := make(map[string]int)
+	var datasets []string
+	for _, batch := range result.Batches {
+		if _, ok := datasetSpans[batch.Dataset]; !ok {
+			datasets = append(datasets, batch.Dataset)
+		}
+		datasetSpans[batch.Dataset] += len(batch.Events)
+		datasetBytes[batch.Dataset] += batch.SizeBytes
+	}
+	for _, dataset := range datasets {
+		limit := l.limitFor(l.config.Dataset, l.config.DatasetOverrides, dataset)
+		key := "dataset\x00" + ri.ApiTenantId + "\x00" + dataset
+		scope := fmt.Sprintf("dataset '%s'", dataset)
+		costs = appendRateLimitCosts(costs, key, scope, limit, 1, datasetSpans[dataset], datasetBytes[dataset])
+	}
+	if len(costs) == 0 {
+		return nil
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var wait time.Duration
+	var rejected *rateLimitCost
+	buckets := make([]*tokenBucket, len(costs))
+	for i := range costs {
+		buckets[i] = l.bucket(costs[i], now)
+		if w := buckets[i].wait(costs[i].tokens); w > wait {
+			wait = w
+			rejected = &costs[i]
+		}
+	}
+	if rejected != nil {
+		err := ErrRateLimited
+		err.Err = fmt.Errorf("%s exceeded %s", rejected.scope, rejected.bucketName)
+		err.RetryAfter = wait
+		return err
+	}
+	for i := range costs {
+		buckets[i].take(costs[i].tokens)
+	}
+	return nil
+}
+
+func (l *RateLimiter) limitFor(defaultLimit RateLimit, overrides map[string]RateLimit, key string) RateLimit {
+	if override, ok := overrides[key]; ok {
+		return override
+	}
+	return defaultLimit
+}
+
+// bucket returns the bucket for the cost, creating a full one if needed
+// l.mu must be held by the caller
+func (l *RateLimiter) bucket(cost rateLimitCost, now time.Time) *tokenBucket {
+	b, ok := l.buckets[cost.key]
+	if !ok || b.rate != cost.rate || b.burst != cost.burst {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.evictFullBuckets(now)
+		}
+		b = &tokenBucket{rate: cost.rate, burst: cost.burst, tokens: cost.burst, last: now}
+		l.buckets[cost.key] = b
+		return b
+	}
+	b.refill(now)
+	return b
+}
+
+// evictFullBuckets drops buckets that have refilled completely, as they are
+// indistinguishable from newly created ones
+func (l *RateLimiter) evictFullBuckets(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func appendRateLimitCosts(costs []rateLimitCost, key string, scope string, limit RateLimit, requests int, spans int, bytes int) []rateLimitCost {
+	burstSeconds := limit.BurstSeconds
+	if burstSeconds <= 0 {
+		burstSeconds = 1
+	}
+	add := func(name string, rate float64, tokens int) {
+		if rate <= 0 {
+			return
+		}
+		costs = append(costs, rateLimitCost{
+			key:        key + "\x00" + name,
+			scope:      scope,
+			rate:       rate,
+			burst:      rate * burstSeconds,
+			tokens:     float64(tokens),
+			bucketName: name + "/sec",
+		})
+	}
+	add("requests", limit.RequestsPerSecond, requests)
+	add("spans", limit.SpansPerSecond, spans)
+	add("bytes", limit.BytesPerSecond, bytes)
+	return costs
+}
+
+// tokenBucket is a classic token bucket refilled continuously at rate tokens per second
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// wait returns how long until n tokens can be taken, zero meaning they can be taken now
+// Costs larger than the burst only need a full bucket, and leave the bucket in debt once taken
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if n > b.burst {
+		n = b.burst
+	}
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	b.tokens -= n
+}
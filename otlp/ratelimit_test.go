@@ -0,0 +1,112 @@
+package otlp
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func rateLimitResult(dataset string, spans int, size int) *TranslateTraceRequestResult {
+	return &TranslateTraceRequestResult{
+		RequestSize: size,
+		Batches: []Batch{{
+			Dataset:   dataset,
+			SizeBytes: size,
+			Events:    make([]Event, spans),
+		}},
+	}
+}
+
+func TestRateLimiterRequestsPerSecond(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Tenant: RateLimit{RequestsPerSecond: 2},
+	})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ri := RequestInfo{ApiTenantId: "tenant-1"}
+
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("dataset", 1, 10)))
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("dataset", 1, 10)))
+
+	err := limiter.AllowTraceRequest(ri, rateLimitResult("dataset", 1, 10))
+	otlpErr, ok := err.(OTLPError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, otlpErr.HTTPStatusCode)
+	assert.Equal(t, codes.ResourceExhausted, otlpErr.GRPCStatusCode)
+	assert.Equal(t, 500*time.Millisecond, otlpErr.RetryAfter)
+
+	// other tenants have their own buckets
+	assert.Nil(t, limiter.AllowTraceRequest(RequestInfo{ApiTenantId: "tenant-2"}, rateLimitResult("dataset", 1, 10)))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("dataset", 1, 10)))
+}
+
+func TestRateLimiterSpansAndBytesPerDataset(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Dataset: RateLimit{SpansPerSecond: 100, BytesPerSecond: 1000},
+		DatasetOverrides: map[string]RateLimit{
+			"big": {SpansPerSecond: 1000, BytesPerSecond: 10000},
+		},
+	})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ri := RequestInfo{ApiTenantId: "tenant-1"}
+
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("small", 60, 100)))
+	err := limiter.AllowTraceRequest(ri, rateLimitResult("small", 60, 100))
+	assert.Error(t, err)
+	assert.Equal(t, 200*time.Millisecond, err.(OTLPError).RetryAfter)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, ErrRateLimited.Message, err.(OTLPError).Message)
+	assert.Equal(t, "dataset 'small' exceeded spans/sec", errors.Unwrap(err).Error())
+
+	// rejected requests are not charged against any bucket
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("small", 40, 100)))
+
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("big", 600, 100)))
+	err = limiter.AllowTraceRequest(ri, rateLimitResult("big", 10, 9950))
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, "dataset 'big' exceeded bytes/sec", errors.Unwrap(err).Error())
+}
+
+func TestRateLimiterAllowsRequestsLargerThanBurst(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Tenant: RateLimit{BytesPerSecond: 100},
+	})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ri := RequestInfo{ApiTenantId: "tenant-1"}
+
+	assert.Nil(t, limiter.AllowTraceRequest(ri, rateLimitResult("dataset", 1, 300)))
+	err := limiter.AllowTraceRequest(ri, rateLimitResult("dataset", 1, 10))
+	assert.Equal(t, 2100*time.Millisecond, err.(OTLPError).RetryAfter)
+}
+
+func TestRateLimitedErrorRendering(t *testing.T) {
+	err := ErrRateLimited
+	err.RetryAfter = 1500 * time.Millisecond
+
+	header := http.Header{}
+	SetRetryAfterHeader(header, err)
+	assert.Equal(t, "2", header.Get("Retry-After"))
+
+	header = http.Header{}
+	SetRetryAfterHeader(header, ErrFailedParseBody)
+	assert.Equal(t, "", header.Get("Retry-After"))
+
+	st := status.Convert(AsGRPCError(err))
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	details := st.Details()
+	assert.Equal(t, 1, len(details))
+	retryInfo, ok := details[0].(*errdetails.RetryInfo)
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, retryInfo.RetryDelay.AsDuration())
+}
@@ -0,0 +1,123 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrServiceUnavailable = OTLPError{Message: "service unavailable", HTTPStatusCode: http.StatusServiceUnavailable, GRPCStatusCode: codes.Unavailable, Retryable: true}
+	ErrBadGateway         = OTLPError{Message: "downstream unavailable", HTTPStatusCode: http.StatusBadGateway, GRPCStatusCode: codes.Unavailable, Retryable: true}
+	ErrGatewayTimeout     = OTLPError{Message: "downstream timed out", HTTPStatusCode: http.StatusGatewayTimeout, GRPCStatusCode: codes.DeadlineExceeded, Retryable: true}
+	ErrPermanent          = OTLPError{Message: "request rejected", HTTPStatusCode: http.StatusBadRequest, GRPCStatusCode: codes.InvalidArgument}
+	ErrInternal           = OTLPError{Message: internalErrorMessage, HTTPStatusCode: http.StatusInternalServerError, GRPCStatusCode: codes.Internal}
+)
+
+// retryableGRPCCodes are the codes the OTLP spec allows clients to retry
+var retryableGRPCCodes = map[codes.Code]bool{
+	codes.Canceled:          true,
+	codes.DeadlineExceeded:  true,
+	codes.Aborted:           true,
+	codes.OutOfRange:        true,
+	codes.Unavailable:       true,
+	codes.DataLoss:          true,
+	codes.ResourceExhausted: true,
+}
+
+// NewRetryableError marks err as temporary, the client should send the request again after retryAfter
+// A zero retryAfter leaves the delay to the client's backoff
+func NewRetryableError(err error, retryAfter time.Duration) OTLPError {
+	e := ErrServiceUnavailable.Wrap(err)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// NewPermanentError marks err as permanent, the client must not send the same request again
+func NewPermanentError(err error) OTLPError {
+	return ErrPermanent.Wrap(err)
+}
+
+// IsRetryable returns true if the client may retry the request that failed with err
+func IsRetryable(err error) bool {
+	return ClassifyError(err).Retryable
+}
+
+// HTTPStatusCode returns the HTTP status to answer with for err, OTLPErrors keep their own status
+// and other errors are 429, 502, 503 or 504 when retryable, 400 when permanent and 500 when unknown
+func HTTPStatusCode(err error) int {
+	return ClassifyError(err).HTTPStatusCode
+}
+
+// GRPCStatusCode returns the gRPC code to answer with for err, OTLPErrors keep their own code and
+// other errors are Unavailable, ResourceExhausted or DeadlineExceeded when retryable, InvalidArgument
+// when permanent and Internal when unknown
+func GRPCStatusCode(err error) codes.Code {
+	return ClassifyError(err).GRPCStatusCode
+}
+
+// ClassifyError converts any error, including those returned by downstream sinks, into an OTLPError
+// OTLPErrors are returned as they are and gRPC status errors keep their code. Only timeouts, network
+// errors and the gRPC codes the spec lists as retryable are retryable. Any other error is an Internal
+// error the client must not retry, so a bug in a sink does not make clients resend forever
+func ClassifyError(err error) OTLPError {
+	var otlpErr OTLPError
+	if errors.As(err, &otlpErr) {
+		return otlpErr
+	}
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		return classifyGRPCCode(st.Code(), err)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrGatewayTimeout.Wrap(err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrGatewayTimeout.Wrap(err)
+		}
+		return ErrBadGateway.Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
+
+func classifyGRPCCode(code codes.Code, err error) OTLPError {
+	switch code {
+	case codes.ResourceExhausted:
+		return ErrRateLimited.Wrap(err)
+	case codes.DeadlineExceeded:
+		return ErrGatewayTimeout.Wrap(err)
+	case codes.Internal:
+		return ErrInternal.Wrap(err)
+	}
+	if retryableGRPCCodes[code] {
+		return ErrServiceUnavailable.Wrap(err)
+	}
+	return ErrPermanent.Wrap(err)
+}
+
+// ClassifyHTTPStatus converts the status of a failed response from a downstream HTTP sink into an OTLPError
+// 429, 502, 503 and 504 are retryable, other 5xx statuses are Internal errors and any other status
+// is permanent
+func ClassifyHTTPStatus(statusCode int, err error) OTLPError {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited.Wrap(err)
+	case http.StatusBadGateway:
+		return ErrBadGateway.Wrap(err)
+	case http.StatusServiceUnavailable:
+		return ErrServiceUnavailable.Wrap(err)
+	case http.StatusGatewayTimeout:
+		return ErrGatewayTimeout.Wrap(err)
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return ErrInternal.Wrap(err)
+	}
+	return ErrPermanent.Wrap(err)
+}
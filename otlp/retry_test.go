@@ -0,0 +1,96 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryable  bool
+		httpStatus int
+		grpcCode   codes.Code
+	}{
+		{"otlp error", ErrFailedParseBody, false, http.StatusBadRequest, codes.InvalidArgument},
+		{"wrapped otlp error", fmt.Errorf("sink: %w", ErrRateLimited), true, http.StatusTooManyRequests, codes.ResourceExhausted},
+		{"permanent", NewPermanentError(errors.New("schema mismatch")), false, http.StatusBadRequest, codes.InvalidArgument},
+		{"retryable", NewRetryableError(errors.New("queue full"), time.Second), true, http.StatusServiceUnavailable, codes.Unavailable},
+		{"grpc unavailable", status.Error(codes.Unavailable, "down"), true, http.StatusServiceUnavailable, codes.Unavailable},
+		{"grpc resource exhausted", status.Error(codes.ResourceExhausted, "slow down"), true, http.StatusTooManyRequests, codes.ResourceExhausted},
+		{"grpc deadline exceeded", status.Error(codes.DeadlineExceeded, "slow"), true, http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{"grpc invalid argument", status.Error(codes.InvalidArgument, "bad"), false, http.StatusBadRequest, codes.InvalidArgument},
+		{"grpc permission denied", status.Error(codes.PermissionDenied, "no"), false, http.StatusBadRequest, codes.InvalidArgument},
+		{"grpc internal", status.Error(codes.Internal, "bug"), false, http.StatusInternalServerError, codes.Internal},
+		{"grpc unknown", status.Error(codes.Unknown, "?"), false, http.StatusInternalServerError, codes.Internal},
+		{"context deadline", fmt.Errorf("export: %w", context.DeadlineExceeded), true, http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{"network timeout", &net.OpError{Op: "dial", Err: timeoutError{}}, true, http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true, http.StatusBadGateway, codes.Unavailable},
+		{"unknown error", errors.New("boom"), false, http.StatusInternalServerError, codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err))
+			assert.Equal(t, tt.httpStatus, HTTPStatusCode(tt.err))
+			assert.Equal(t, tt.grpcCode, GRPCStatusCode(tt.err))
+		})
+	}
+}
+
+func TestClassifyErrorKeepsCause(t *testing.T) {
+	cause := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+	err := ClassifyError(cause)
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(err, ErrBadGateway))
+	// the cause is not sent to the client
+	assert.Equal(t, "rpc error: code = Unavailable desc = downstream unavailable", AsGRPCError(err).Error())
+}
+
+func TestClassifyHTTPStatus(t *testing.T) {
+	cause := errors.New("sink failed")
+	for statusCode, retryable := range map[int]bool{
+		http.StatusTooManyRequests:    true,
+		http.StatusBadGateway:         true,
+		http.StatusServiceUnavailable: true,
+		http.StatusGatewayTimeout:     true,
+		http.StatusBadRequest:         false,
+		http.StatusUnauthorized:       false,
+	} {
+		err := ClassifyHTTPStatus(statusCode, cause)
+		assert.Equal(t, retryable, err.Retryable, statusCode)
+		if retryable {
+			assert.Equal(t, statusCode, err.HTTPStatusCode)
+		} else {
+			assert.Equal(t, http.StatusBadRequest, err.HTTPStatusCode)
+			assert.Equal(t, codes.InvalidArgument, err.GRPCStatusCode)
+		}
+	}
+
+	err := ClassifyHTTPStatus(http.StatusInternalServerError, cause)
+	assert.False(t, err.Retryable)
+	assert.True(t, errors.Is(err, ErrInternal))
+}
+
+func TestNewRetryableErrorSetsRetryAfter(t *testing.T) {
+	err := NewRetryableError(errors.New("queue full"), 2*time.Second)
+	assert.Equal(t, 2*time.Second, err.RetryAfter)
+	header := http.Header{}
+	SetRetryAfterHeader(header, err)
+	assert.Equal(t, "2", header.Get("Retry-After"))
+}
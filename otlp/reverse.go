@@ -0,0 +1,256 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// TranslateResultToTraceReq rebuilds an OTLP trace request from the events produced by TranslateTraceReq
+// Spans are grouped by their resourceAttributes and by instrumentation library. The translation is
+// lossy: span events were merged into eventAttributes so they come back as a single span event, links
+// are only counted so they are not restored, and array or kvlist attributes come back as JSON strings
+// Events that do not describe a span are skipped
+func TranslateResultToTraceReq(result *TranslateTraceRequestResult) (*collectorTrace.ExportTraceServiceRequest, error) {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	resourceSpansByKey := make(map[string]*trace.ResourceSpans)
+	librarySpansByKey := make(map[string]*trace.ScopeSpans)
+
+	for _, batch := range result.Batches {
+		for _, ev := range batch.Events {
+			if _, ok := ev.Attributes["traceSpanID"]; !ok {
+				continue
+			}
+
+			resourceAttrs, _ := ev.Attributes["resourceAttributes"].(map[string]interface{})
+			libraryName, _ := resourceAttrs["library.name"].(string)
+			libraryVersion, _ := resourceAttrs["library.version"].(string)
+			librarySchemaURL, _ := resourceAttrs["library.schema_url"].(string)
+			resourceKey, err := resourceGroupKey(resourceAttrs)
+			if err != nil {
+				return nil, err
+			}
+
+			resourceSpans, ok := resourceSpansByKey[resourceKey]
+			if !ok {
+				resourceSpans = &trace.ResourceSpans{
+					Resource:  &resource.Resource{Attributes: mapToKeyValues(resourceAttrs, "library.name", "library.version", "library.schema_url", "resource.schema_url")},
+					SchemaUrl: stringAttribute(resourceAttrs, "resource.schema_url"),
+				}
+				resourceSpansByKey[resourceKey] = resourceSpans
+				request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+			}
+
+			libraryKey := resourceKey + "\x00" + libraryName + "\x00" + libraryVersion + "\x00" + librarySchemaURL
+			librarySpans, ok := librarySpansByKey[libraryKey]
+			if !ok {
+				librarySpans = &trace.ScopeSpans{SchemaUrl: librarySchemaURL}
+				if libraryName != "" || libraryVersion != "" {
+					librarySpans.Scope = &common.InstrumentationScope{Name: libraryName, Version: libraryVersion}
+				}
+				librarySpansByKey[libraryKey] = librarySpans
+				resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, librarySpans)
+			}
+
+			span, err := eventToSpan(ev)
+			if err != nil {
+				return nil, err
+			}
+			librarySpans.Spans = append(librarySpans.Spans, span)
+		}
+	}
+	return request, nil
+}
+
+// resourceGroupKey returns a key identifying a resource by its attribute values
+// encoding/json sorts map keys, so equal maps always produce the same key
+func resourceGroupKey(resourceAttrs map[string]interface{}) (string, error) {
+	attrs := make(map[string]interface{}, len(resourceAttrs))
+	for k, v := range resourceAttrs {
+		if k != "library.name" && k != "library.version" && k != "library.schema_url" {
+			attrs[k] = v
+		}
+	}
+	key, err := json.Marshal(attrs)
+	if err != nil {
+		return "", fmt.Errorf("failed to group resource attributes: %w", err)
+	}
+	return string(key), nil
+}
+
+func eventToSpan(ev Event) (*trace.Span, error) {
+	attrs := ev.Attributes
+	traceID, err := traceIDFromHex(stringAttribute(attrs, "traceTraceID"))
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := hex.DecodeString(stringAttribute(attrs, "traceSpanID"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid span ID: %w", err)
+	}
+
+	span := &trace.Span{
+		TraceId:                traceID,
+		SpanId:                 spanID,
+		Name:                   stringAttribute(attrs, "spanName"),
+		Kind:                   spanKindFromString(stringAttribute(attrs, "spanKind")),
+		StartTimeUnixNano:      uint64(int64Attribute(attrs, "startTime")),
+		EndTimeUnixNano:        uint64(int64Attribute(attrs, "endTime")),
+		TraceState:             stringAttribute(attrs, "traceState"),
+		Flags:                  uint32(int64Attribute(attrs, "spanFlags")),
+		DroppedAttributesCount: uint32(int64Attribute(attrs, "spanDroppedAttributes")),
+		DroppedEventsCount:     uint32(int64Attribute(attrs, "spanDroppedEvents")),
+		DroppedLinksCount:      uint32(int64Attribute(attrs, "spanDroppedLinks")),
+	}
+	if parentID, ok := attrs["traceParentID"].(string); ok {
+		if span.ParentSpanId, err = hex.DecodeString(parentID); err != nil {
+			return nil, fmt.Errorf("invalid parent span ID: %w", err)
+		}
+	}
+
+	statusCode, _ := attrs["statusCode"].(trace.Status_StatusCode)
+	statusMessage := stringAttribute(attrs, "statusMessage")
+	if statusCode != trace.Status_STATUS_CODE_UNSET || statusMessage != "" {
+		span.Status = &trace.Status{Code: statusCode, Message: statusMessage}
+		if statusCode == trace.Status_STATUS_CODE_ERROR {
+			// keep the deprecated code consistent so older receivers also see an error
+			setDeprecatedStatusCode(span.Status, deprecatedStatusCodeUnknownError)
+		}
+	}
+
+	if spanAttrs, ok := attrs["spanAttributes"].(map[string]interface{}); ok {
+		span.Attributes = mapToKeyValues(spanAttrs)
+	}
+	if eventAttrs, ok := attrs["eventAttributes"].(map[string]interface{}); ok && len(eventAttrs) > 0 {
+		span.Events = []*trace.Span_Event{{
+			TimeUnixNano: span.StartTimeUnixNano,
+			Attributes:   mapToKeyValues(eventAttrs),
+		}}
+		// the merged attributes of spans with exceptions hold the exception attributes
+		if _, ok := attrs["exceptionCount"]; ok {
+			span.Events[0].Name = exceptionEventName
+		}
+	}
+	return span, nil
+}
+
+// traceIDFromHex reverses BytesToTraceID, restoring the zero padding of 64-bit trace IDs
+func traceIDFromHex(traceID string) ([]byte, error) {
+	decoded, err := hex.DecodeString(traceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace ID: %w", err)
+	}
+	if len(decoded) == traceIDShortLength {
+		padded := make([]byte, traceIDLongLength)
+		copy(padded[traceIDLongLength-traceIDShortLength:], decoded)
+		return padded, nil
+	}
+	return decoded, nil
+}
+
+func spanKindFromString(kind string) trace.Span_SpanKind {
+	switch kind {
+	case "client":
+		return trace.Span_SPAN_KIND_CLIENT
+	case "server":
+		return trace.Span_SPAN_KIND_SERVER
+	case "producer":
+		return trace.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return trace.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return trace.Span_SPAN_KIND_INTERNAL
+	default:
+		return trace.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func stringAttribute(attrs map[string]interface{}, key string) string {
+	s, _ := attrs[key].(string)
+	return s
+}
+
+func int64Attribute(attrs map[string]interface{}, key string) int64 {
+	switch v := attrs[key].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// mapToKeyValues converts an attribute map back to OTLP key values, sorted by key
+func mapToKeyValues(attrs map[string]interface{}, skipKeys ...string) []*common.KeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var kvs []*common.KeyValue
+	for _, k := range keys {
+		if containsString(skipKeys, k) {
+			continue
+		}
+		if value := toAnyValue(attrs[k]); value != nil {
+			kvs = append(kvs, &common.KeyValue{Key: k, Value: value})
+		}
+	}
+	return kvs
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// toAnyValue is the inverse of getValue, returning nil for values that cannot be represented
+func toAnyValue(v interface{}) *common.AnyValue {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: val}}
+	case bool:
+		return &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: val}}
+	case int64:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: val}}
+	case float64:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: val}}
+	case []interface{}:
+		values := make([]*common.AnyValue, 0, len(val))
+		for _, item := range val {
+			if value := toAnyValue(item); value != nil {
+				values = append(values, value)
+			}
+		}
+		return &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{Values: values}}}
+	case map[string]interface{}:
+		return &common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{Values: mapToKeyValues(val)}}}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: rv.Int()}}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(rv.Uint())}}
+	case reflect.Float32, reflect.Float64:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: rv.Float()}}
+	}
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+}
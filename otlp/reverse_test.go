@@ -0,0 +1,115 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func newReverseTestRequest() *collectortrace.ExportTraceServiceRequest {
+	shortTraceID := append(make([]byte, 8), test.RandomBytes(8)...)
+
+	root := newTestSpan("GET /")
+	root.TraceId = shortTraceID
+	root.Kind = trace.Span_SPAN_KIND_SERVER
+	root.EndTimeUnixNano = root.StartTimeUnixNano + uint64(20*time.Millisecond)
+	root.Status = &trace.Status{Code: trace.Status_STATUS_CODE_OK}
+	root.Attributes = []*common.KeyValue{
+		stringAttr("http.method", "GET"),
+		{Key: "http.status_code", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 200}}},
+		{Key: "sampled", Value: &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}}},
+		{Key: "ratio", Value: &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: 0.25}}},
+	}
+
+	child := newTestSpan("render")
+	child.TraceId = shortTraceID
+	child.ParentSpanId = root.SpanId
+	child.Kind = trace.Span_SPAN_KIND_INTERNAL
+	child.StartTimeUnixNano = root.StartTimeUnixNano + uint64(time.Millisecond)
+	child.EndTimeUnixNano = root.StartTimeUnixNano + uint64(10*time.Millisecond)
+	child.Status = &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "template missing"}
+	child.TraceState = "vendor=value"
+	child.DroppedLinksCount = 2
+	child.Events = []*trace.Span_Event{{
+		Name:         "exception",
+		TimeUnixNano: root.StartTimeUnixNano + uint64(5*time.Millisecond),
+		Attributes:   []*common.KeyValue{stringAttr("exception.type", "TemplateError")},
+	}}
+
+	query := newTestSpan("query")
+	query.Kind = trace.Span_SPAN_KIND_CLIENT
+
+	request := newTestRequest(root, child)
+	request.ResourceSpans[0].Resource.Attributes = []*common.KeyValue{stringAttr("service.name", "frontend"), stringAttr("host.name", "host-1")}
+	request.ResourceSpans[0].ScopeSpans[0].Scope = &common.InstrumentationScope{Name: "http", Version: "1.0"}
+	backend := newTestRequest(query).ResourceSpans[0]
+	backend.Resource.Attributes = []*common.KeyValue{stringAttr("service.name", "backend")}
+	request.ResourceSpans = append(request.ResourceSpans, backend)
+	return request
+}
+
+func TestTranslateResultToTraceReqRoundTrip(t *testing.T) {
+	ri := RequestInfo{Dataset: "dataset"}
+	original, err := TranslateTraceReq(newReverseTestRequest(), ri)
+	require.NoError(t, err)
+
+	rebuilt, err := TranslateResultToTraceReq(original)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(rebuilt.ResourceSpans))
+
+	roundTripped, err := TranslateTraceReq(rebuilt, ri)
+	require.NoError(t, err)
+	require.Equal(t, len(original.Batches), len(roundTripped.Batches))
+	for i := range original.Batches {
+		assert.Equal(t, original.Batches[i].Events, roundTripped.Batches[i].Events)
+	}
+}
+
+func TestTranslateResultToTraceReqRestoresSpanFields(t *testing.T) {
+	request := newReverseTestRequest()
+	result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+
+	rebuilt, err := TranslateResultToTraceReq(result)
+	require.NoError(t, err)
+
+	resourceSpans := rebuilt.ResourceSpans[0]
+	assert.Equal(t, []*common.KeyValue{stringAttr("host.name", "host-1"), stringAttr("service.name", "frontend")}, resourceSpans.Resource.Attributes)
+	assert.Equal(t, 1, len(resourceSpans.ScopeSpans))
+	library := resourceSpans.ScopeSpans[0]
+	assert.Equal(t, "http", library.Scope.Name)
+	assert.Equal(t, "1.0", library.Scope.Version)
+
+	originalSpan := request.ResourceSpans[0].ScopeSpans[0].Spans[1]
+	span := library.Spans[1]
+	assert.Equal(t, originalSpan.TraceId, span.TraceId)
+	assert.Equal(t, originalSpan.SpanId, span.SpanId)
+	assert.Equal(t, originalSpan.ParentSpanId, span.ParentSpanId)
+	assert.Equal(t, originalSpan.Kind, span.Kind)
+	assert.Equal(t, originalSpan.StartTimeUnixNano, span.StartTimeUnixNano)
+	assert.Equal(t, originalSpan.EndTimeUnixNano, span.EndTimeUnixNano)
+	assert.Equal(t, trace.Status_STATUS_CODE_ERROR, span.Status.Code)
+	assert.Equal(t, "template missing", span.Status.Message)
+	assert.Equal(t, originalSpan.TraceState, span.TraceState)
+	assert.Equal(t, originalSpan.DroppedLinksCount, span.DroppedLinksCount)
+	assert.Equal(t, 1, len(span.Events))
+}
+
+func TestTranslateResultToTraceReqRejectsInvalidIDs(t *testing.T) {
+	result := &TranslateTraceRequestResult{
+		Batches: []Batch{{
+			Events: []Event{{Attributes: map[string]interface{}{
+				"traceTraceID": "not-hex",
+				"traceSpanID":  "0102030405060708",
+			}}},
+		}},
+	}
+	_, err := TranslateResultToTraceReq(result)
+	assert.Error(t, err)
+}
@@ -0,0 +1,280 @@
+package otlp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaRenames lists the attributes renamed by a semantic convention version, old name to new name
+type SchemaRenames struct {
+	Version    string            `json:"version" yaml:"version"`
+	Attributes map[string]string `json:"attributes" yaml:"attributes"`
+}
+
+// builtinSchemaRenames are the attribute renames from the OpenTelemetry schema files
+var builtinSchemaRenames = []SchemaRenames{
+	{Version: "1.20.0", Attributes: map[string]string{
+		"net.app.protocol.name":    "net.protocol.name",
+		"net.app.protocol.version": "net.protocol.version",
+	}},
+	{Version: "1.21.0", Attributes: map[string]string{
+		"http.method":                      "http.request.method",
+		"http.status_code":                 "http.response.status_code",
+		"http.scheme":                      "url.scheme",
+		"http.url":                         "url.full",
+		"http.request_content_length":      "http.request.body.size",
+		"http.response_content_length":     "http.response.body.size",
+		"http.client_ip":                   "client.address",
+		"net.peer.name":                    "server.address",
+		"net.peer.port":                    "server.port",
+		"net.sock.peer.addr":               "network.peer.address",
+		"net.sock.peer.port":               "network.peer.port",
+		"net.protocol.name":                "network.protocol.name",
+		"net.protocol.version":             "network.protocol.version",
+		"messaging.kafka.client_id":        "messaging.client_id",
+		"messaging.rocketmq.client_id":     "messaging.client_id",
+		"messaging.kafka.source.partition": "messaging.kafka.destination.partition",
+	}},
+	{Version: "1.22.0", Attributes: map[string]string{
+		"messaging.message.payload_size_bytes": "messaging.message.body.size",
+	}},
+	{Version: "1.26.0", Attributes: map[string]string{
+		"db.statement": "db.query.text",
+		"db.operation": "db.operation.name",
+		"db.name":      "db.namespace",
+	}},
+}
+
+// SchemaMigratorConfig configures a SchemaMigrator
+// TargetVersion is the semantic convention version events are migrated to, defaulting to the newest known version
+// Renames are merged into the built-in tables and replace built-in renames of the same attribute
+type SchemaMigratorConfig struct {
+	TargetVersion string
+	Renames       []SchemaRenames
+}
+
+// SchemaMigrator renames attributes so events from different semantic convention versions use the target version
+// The source version is taken from library.schema_url, then resource.schema_url, events without either are
+// treated as older than every known version
+// Attributes are only renamed forwards and never overwrite an attribute that is already set
+type SchemaMigrator struct {
+	target   schemaVersion
+	versions []schemaVersionRenames
+}
+
+type schemaVersion []int
+
+type schemaVersionRenames struct {
+	version schemaVersion
+	renames []attributeRename
+}
+
+// attributeRename is one rename of a version, kept sorted by the old name so attributes that
+// are renamed to the same new name are always resolved the same way
+type attributeRename struct {
+	from string
+	to   string
+}
+
+// NewSchemaMigrator creates a migrator from the built-in and configured rename tables
+func NewSchemaMigrator(config SchemaMigratorConfig) (*SchemaMigrator, error) {
+	type versionEntry struct {
+		version    schemaVersion
+		attributes map[string]string
+	}
+	byVersion := map[string]*versionEntry{}
+	var versions []*versionEntry
+	for _, renames := range append(append([]SchemaRenames{}, builtinSchemaRenames...), config.Renames...) {
+		version, err := parseSchemaVersion(renames.Version)
+		if err != nil {
+			return nil, err
+		}
+		key := version.String()
+		entry, ok := byVersion[key]
+		if !ok {
+			entry = &versionEntry{version: version, attributes: map[string]string{}}
+			byVersion[key] = entry
+			versions = append(versions, entry)
+		}
+		for from, to := range renames.Attributes {
+			if from == "" || to == "" {
+				return nil, fmt.Errorf("schema %s: empty attribute name in rename %q to %q", renames.Version, from, to)
+			}
+			entry.attributes[from] = to
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].version.compare(versions[j].version) < 0
+	})
+
+	m := &SchemaMigrator{}
+	for _, entry := range versions {
+		renames := make([]attributeRename, 0, len(entry.attributes))
+		for from, to := range entry.attributes {
+			renames = append(renames, attributeRename{from: from, to: to})
+		}
+		sort.Slice(renames, func(i, j int) bool {
+			return renames[i].from < renames[j].from
+		})
+		m.versions = append(m.versions, schemaVersionRenames{version: entry.version, renames: renames})
+	}
+	if config.TargetVersion != "" {
+		target, err := parseSchemaVersion(config.TargetVersion)
+		if err != nil {
+			return nil, err
+		}
+		m.target = target
+	} else if len(m.versions) > 0 {
+		m.target = m.versions[len(m.versions)-1].version
+	}
+	return m, nil
+}
+
+// ParseSchemaRenames parses YAML or JSON rename tables, a list of versions with their attribute renames
+func ParseSchemaRenames(data []byte) ([]SchemaRenames, error) {
+	var renames []SchemaRenames
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&renames); err != nil {
+		return nil, fmt.Errorf("failed to parse schema renames: %w", err)
+	}
+	return renames, nil
+}
+
+// LoadSchemaRenames reads YAML or JSON rename tables from a file
+func LoadSchemaRenames(path string) ([]SchemaRenames, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchemaRenames(data)
+}
+
+// Migrate renames the resource, span and event attributes of ev to the target version
+// The resource attribute map can be shared between events, so it is copied before it is modified
+func (m *SchemaMigrator) Migrate(ev *Event) {
+	if m == nil {
+		return
+	}
+	resourceAttrs, _ := ev.Attributes["resourceAttributes"].(map[string]interface{})
+	source := sourceSchemaVersion(resourceAttrs)
+	var renames [][]attributeRename
+	for _, entry := range m.versions {
+		if entry.version.compare(source) > 0 && entry.version.compare(m.target) <= 0 {
+			renames = append(renames, entry.renames)
+		}
+	}
+	if len(renames) == 0 {
+		return
+	}
+
+	if migrated, changed := migrateAttributes(resourceAttrs, renames, true); changed {
+		ev.Attributes["resourceAttributes"] = migrated
+	}
+	for _, key := range []string{"spanAttributes", "eventAttributes"} {
+		if attrs, ok := ev.Attributes[key].(map[string]interface{}); ok {
+			migrateAttributes(attrs, renames, false)
+		}
+	}
+}
+
+// migrateAttributes applies the renames in order, copying attrs before the first change when copyOnWrite is set
+// When several attributes are renamed to the same name the first in the table wins and the others are kept
+func migrateAttributes(attrs map[string]interface{}, renames [][]attributeRename, copyOnWrite bool) (map[string]interface{}, bool) {
+	changed := false
+	for _, table := range renames {
+		for _, rename := range table {
+			from, to := rename.from, rename.to
+			val, ok := attrs[from]
+			if !ok {
+				continue
+			}
+			if _, exists := attrs[to]; exists {
+				continue
+			}
+			if copyOnWrite && !changed {
+				copied := make(map[string]interface{}, len(attrs))
+				for k, v := range attrs {
+					copied[k] = v
+				}
+				attrs = copied
+			}
+			changed = true
+			delete(attrs, from)
+			attrs[to] = val
+		}
+	}
+	return attrs, changed
+}
+
+// sourceSchemaVersion returns the version from the scope or resource schema URL, scope takes precedence
+func sourceSchemaVersion(resourceAttrs map[string]interface{}) schemaVersion {
+	for _, key := range []string{"library.schema_url", "resource.schema_url"} {
+		if url, ok := resourceAttrs[key].(string); ok && url != "" {
+			if version, err := parseSchemaVersion(url[strings.LastIndex(url, "/")+1:]); err == nil {
+				return version
+			}
+		}
+	}
+	return nil
+}
+
+func parseSchemaVersion(version string) (schemaVersion, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	parsed := make(schemaVersion, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid schema version %q", version)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// compare returns -1, 0 or 1, missing parts count as zero and a nil version is older than every other
+func (v schemaVersion) compare(other schemaVersion) int {
+	if v == nil || other == nil {
+		switch {
+		case v == nil && other == nil:
+			return 0
+		case v == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	for i := 0; i < len(v) || i < len(other); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v schemaVersion) String() string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	return strings.Join(parts, ".")
+}
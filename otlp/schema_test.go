@@ -0,0 +1,157 @@
+package otlp
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func newSchemaEvent(resourceAttrs map[string]interface{}) Event {
+	return Event{Attributes: map[string]interface{}{
+		"resourceAttributes": resourceAttrs,
+		"spanAttributes": map[string]interface{}{
+			"http.method":           "GET",
+			"net.peer.name":         "example.com",
+			"net.app.protocol.name": "http",
+			"db.statement":          "SELECT 1",
+		},
+	}}
+}
+
+func TestSchemaMigratorMigratesToNewestVersion(t *testing.T) {
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{})
+	require.NoError(t, err)
+
+	ev := newSchemaEvent(map[string]interface{}{"resource.schema_url": "https://opentelemetry.io/schemas/1.9.0"})
+	migrator.Migrate(&ev)
+	assert.Equal(t, map[string]interface{}{
+		"http.request.method":   "GET",
+		"server.address":        "example.com",
+		"network.protocol.name": "http",
+		"db.query.text":         "SELECT 1",
+	}, ev.Attributes["spanAttributes"])
+}
+
+func TestSchemaMigratorSourceVersion(t *testing.T) {
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{})
+	require.NoError(t, err)
+
+	// the scope schema takes precedence over the resource schema
+	ev := newSchemaEvent(map[string]interface{}{
+		"resource.schema_url": "https://opentelemetry.io/schemas/1.9.0",
+		"library.schema_url":  "https://opentelemetry.io/schemas/1.21.0",
+	})
+	migrator.Migrate(&ev)
+	assert.Equal(t, map[string]interface{}{
+		"http.method":           "GET",
+		"net.peer.name":         "example.com",
+		"net.app.protocol.name": "http",
+		"db.query.text":         "SELECT 1",
+	}, ev.Attributes["spanAttributes"])
+}
+
+func TestSchemaMigratorTargetVersion(t *testing.T) {
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{TargetVersion: "1.20.0"})
+	require.NoError(t, err)
+
+	ev := newSchemaEvent(map[string]interface{}{})
+	migrator.Migrate(&ev)
+	assert.Equal(t, map[string]interface{}{
+		"http.method":       "GET",
+		"net.peer.name":     "example.com",
+		"net.protocol.name": "http",
+		"db.statement":      "SELECT 1",
+	}, ev.Attributes["spanAttributes"])
+}
+
+func TestSchemaMigratorDoesNotOverwrite(t *testing.T) {
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{})
+	require.NoError(t, err)
+
+	ev := Event{Attributes: map[string]interface{}{
+		"spanAttributes": map[string]interface{}{"http.method": "get", "http.request.method": "GET"},
+	}}
+	migrator.Migrate(&ev)
+	assert.Equal(t, map[string]interface{}{"http.method": "get", "http.request.method": "GET"}, ev.Attributes["spanAttributes"])
+}
+
+func TestSchemaMigratorRenameCollision(t *testing.T) {
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{})
+	require.NoError(t, err)
+
+	// both attributes are renamed to messaging.client_id, the result must not depend on map order
+	for i := 0; i < 20; i++ {
+		ev := Event{Attributes: map[string]interface{}{
+			"spanAttributes": map[string]interface{}{
+				"messaging.kafka.client_id":    "kafka",
+				"messaging.rocketmq.client_id": "rocketmq",
+			},
+		}}
+		migrator.Migrate(&ev)
+		assert.Equal(t, map[string]interface{}{
+			"messaging.client_id":          "kafka",
+			"messaging.rocketmq.client_id": "rocketmq",
+		}, ev.Attributes["spanAttributes"])
+	}
+}
+
+func TestSchemaMigratorCustomRenames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "renames.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+- version: 1.21.0
+  attributes:
+    http.method: http.verb
+- version: 2.0.0
+  attributes:
+    team: service.team
+`), 0600))
+	renames, err := LoadSchemaRenames(path)
+	require.NoError(t, err)
+
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{Renames: renames})
+	require.NoError(t, err)
+	resourceAttrs := map[string]interface{}{"team": "payments"}
+	ev := Event{Attributes: map[string]interface{}{
+		"resourceAttributes": resourceAttrs,
+		"spanAttributes":     map[string]interface{}{"http.method": "GET"},
+	}}
+	migrator.Migrate(&ev)
+	assert.Equal(t, map[string]interface{}{"http.verb": "GET"}, ev.Attributes["spanAttributes"])
+	assert.Equal(t, map[string]interface{}{"service.team": "payments"}, ev.Attributes["resourceAttributes"])
+	// shared resource maps are copied rather than modified
+	assert.Equal(t, map[string]interface{}{"team": "payments"}, resourceAttrs)
+}
+
+func TestSchemaMigratorConfigErrors(t *testing.T) {
+	_, err := NewSchemaMigrator(SchemaMigratorConfig{TargetVersion: "latest"})
+	assert.Error(t, err)
+	_, err = NewSchemaMigrator(SchemaMigratorConfig{Renames: []SchemaRenames{{Version: "1.x", Attributes: map[string]string{"a": "b"}}}})
+	assert.Error(t, err)
+	_, err = NewSchemaMigrator(SchemaMigratorConfig{Renames: []SchemaRenames{{Version: "1.0.0", Attributes: map[string]string{"a": ""}}}})
+	assert.Error(t, err)
+	_, err = ParseSchemaRenames([]byte(`[{"version": "1.0.0", "renames": {"a": "b"}}]`))
+	assert.Error(t, err)
+}
+
+func TestTranslateTraceReqWithSchemaMigrator(t *testing.T) {
+	migrator, err := NewSchemaMigrator(SchemaMigratorConfig{})
+	require.NoError(t, err)
+	span := newTestSpan("GET")
+	span.Attributes = []*common.KeyValue{
+		stringAttr("http.method", "GET"),
+		stringAttr("http.route", "/users/{id}"),
+	}
+	req := newTestRequest(span)
+	req.ResourceSpans[0].Resource.Attributes = []*common.KeyValue{stringAttr("service.name", "checkout")}
+	req.ResourceSpans[0].SchemaUrl = "https://opentelemetry.io/schemas/1.17.0"
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"}, WithSchemaMigrator(migrator), WithSemconvFields())
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, map[string]interface{}{"http.request.method": "GET", "http.route": "/users/{id}"}, attrs["spanAttributes"])
+	assert.Equal(t, "GET /users/{id}", attrs["httpRouteName"])
+}
@@ -0,0 +1,119 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestTranslateTraceReqMultipleLibrariesInResource(t *testing.T) {
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+			ScopeSpans: []*trace.ScopeSpans{
+				{
+					Scope: &common.InstrumentationScope{Name: "http", Version: "1.0", Attributes: []*common.KeyValue{stringAttr("http_attr", "val")}},
+					Spans: []*trace.Span{newTestSpan("http_span_1"), newTestSpan("http_span_2")},
+				},
+				{
+					Scope:     &common.InstrumentationScope{Name: "db"},
+					SchemaUrl: "https://opentelemetry.io/schemas/1.12.0",
+					Spans:     []*trace.Span{newTestSpan("db_span")},
+				},
+				{
+					Spans: []*trace.Span{newTestSpan("no_scope_span")},
+				},
+			},
+		}},
+	}
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 4, len(events))
+
+	httpAttrs := map[string]interface{}{
+		"service.name":    "my-service",
+		"library.name":    "http",
+		"library.version": "1.0",
+		"http_attr":       "val",
+	}
+	assert.Equal(t, httpAttrs, events[0].Attributes["resourceAttributes"])
+	assert.Equal(t, httpAttrs, events[1].Attributes["resourceAttributes"])
+	assert.Equal(t, map[string]interface{}{
+		"service.name":       "my-service",
+		"library.name":       "db",
+		"library.schema_url": "https://opentelemetry.io/schemas/1.12.0",
+	}, events[2].Attributes["resourceAttributes"])
+	assert.Equal(t, map[string]interface{}{"service.name": "my-service"}, events[3].Attributes["resourceAttributes"])
+}
+
+func TestAddScopeAttributesDoesNotModifyResource(t *testing.T) {
+	resourceAttrs := map[string]interface{}{"service.name": "my-service"}
+
+	attrs, counts := addScopeAttributes(resourceAttrs, &trace.ScopeSpans{
+		Scope: &common.InstrumentationScope{Name: "http", Attributes: []*common.KeyValue{{Key: ""}}},
+	})
+	assert.Equal(t, 1, counts.dropped)
+	assert.Equal(t, map[string]interface{}{"service.name": "my-service", "library.name": "http"}, attrs)
+	assert.Equal(t, map[string]interface{}{"service.name": "my-service"}, resourceAttrs)
+
+	attrs, counts = addScopeAttributes(resourceAttrs, &trace.ScopeSpans{})
+	assert.Equal(t, 0, counts.dropped)
+	assert.Equal(t, resourceAttrs, attrs)
+}
+
+func TestTranslateTraceReqDroppedCounts(t *testing.T) {
+	truncatedSpan := newTestSpan("truncated_span")
+	truncatedSpan.TraceState = "vendor=value"
+	truncatedSpan.DroppedAttributesCount = 1
+	truncatedSpan.DroppedEventsCount = 2
+	truncatedSpan.DroppedLinksCount = 3
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{
+			{
+				Resource: &resource.Resource{DroppedAttributesCount: 4},
+				ScopeSpans: []*trace.ScopeSpans{
+					{
+						Scope: &common.InstrumentationScope{Name: "http", DroppedAttributesCount: 5},
+						Spans: []*trace.Span{truncatedSpan},
+					},
+					{
+						Spans: []*trace.Span{newTestSpan("span")},
+					},
+				},
+			},
+			{
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{newTestSpan("complete_span")}}},
+			},
+		},
+	}
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.True(t, result.Batches[0].Truncated)
+	assert.False(t, result.Batches[1].Truncated)
+
+	truncated := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "vendor=value", truncated["traceState"])
+	assert.Equal(t, 4, truncated["resourceDroppedAttributes"])
+	assert.Equal(t, 5, truncated["libraryDroppedAttributes"])
+	assert.Equal(t, 1, truncated["spanDroppedAttributes"])
+	assert.Equal(t, 2, truncated["spanDroppedEvents"])
+	assert.Equal(t, 3, truncated["spanDroppedLinks"])
+
+	span := result.Batches[0].Events[1].Attributes
+	assert.Equal(t, 4, span["resourceDroppedAttributes"])
+	assert.NotContains(t, span, "libraryDroppedAttributes")
+	assert.NotContains(t, span, "spanDroppedAttributes")
+	assert.NotContains(t, span, "traceState")
+
+	complete := result.Batches[1].Events[0].Attributes
+	assert.NotContains(t, complete, "resourceDroppedAttributes")
+}
@@ -0,0 +1,85 @@
+package otlp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute names from the semantic conventions, older names are listed after the current ones
+var (
+	httpMethodKeys     = []string{"http.request.method", "http.method"}
+	httpStatusCodeKeys = []string{"http.response.status_code", "http.status_code"}
+	dbSystemKeys       = []string{"db.system.name", "db.system"}
+	dbOperationKeys    = []string{"db.operation.name", "db.operation"}
+	grpcStatusCodeKeys = []string{"rpc.grpc.status_code"}
+)
+
+const httpRouteKey = "http.route"
+
+// AddSemconvFields derives top-level fields from the semantic convention attributes in spanAttributes
+//   - httpRouteName, the request method and http.route such as "GET /users/{id}"
+//   - httpStatusClass, the response status code class such as "4xx"
+//   - dbOperation, db.system and the operation such as "postgresql SELECT"
+//   - rpcError, true when rpc.grpc.status_code is not OK
+//
+// Both the current and the older attribute names are read, fields that cannot be derived are left unset
+func AddSemconvFields(attrs map[string]interface{}) {
+	spanAttrs, _ := attrs["spanAttributes"].(map[string]interface{})
+	if len(spanAttrs) == 0 {
+		return
+	}
+
+	method := firstString(spanAttrs, httpMethodKeys)
+	if route, ok := spanAttrs[httpRouteKey].(string); ok && route != "" {
+		if method != "" {
+			attrs["httpRouteName"] = method + " " + route
+		} else {
+			attrs["httpRouteName"] = route
+		}
+	}
+
+	if code, ok := firstInt(spanAttrs, httpStatusCodeKeys); ok && code >= 100 && code < 600 {
+		attrs["httpStatusClass"] = fmt.Sprintf("%dxx", code/100)
+	}
+
+	if system := firstString(spanAttrs, dbSystemKeys); system != "" {
+		if operation := firstString(spanAttrs, dbOperationKeys); operation != "" {
+			attrs["dbOperation"] = system + " " + operation
+		} else {
+			attrs["dbOperation"] = system
+		}
+	}
+
+	if code, ok := firstInt(spanAttrs, grpcStatusCodeKeys); ok {
+		attrs["rpcError"] = code != 0
+	}
+}
+
+func firstString(attrs map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if val, ok := attrs[key].(string); ok && val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// firstInt returns the first of the keys holding a number, numbers sent as strings are parsed
+func firstInt(attrs map[string]interface{}, keys []string) (int64, bool) {
+	for _, key := range keys {
+		switch val := attrs[key].(type) {
+		case int64:
+			return val, true
+		case int:
+			return int64(val), true
+		case float64:
+			return int64(val), true
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func TestAddSemconvFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		spanAttrs map[string]interface{}
+		expected  map[string]interface{}
+	}{
+		{
+			name:      "new http names",
+			spanAttrs: map[string]interface{}{"http.request.method": "GET", "http.route": "/users/{id}", "http.response.status_code": int64(404)},
+			expected:  map[string]interface{}{"httpRouteName": "GET /users/{id}", "httpStatusClass": "4xx"},
+		},
+		{
+			name:      "old http names",
+			spanAttrs: map[string]interface{}{"http.method": "POST", "http.route": "/orders", "http.status_code": "503"},
+			expected:  map[string]interface{}{"httpRouteName": "POST /orders", "httpStatusClass": "5xx"},
+		},
+		{
+			name:      "route without method",
+			spanAttrs: map[string]interface{}{"http.route": "/health", "http.status_code": float64(200)},
+			expected:  map[string]interface{}{"httpRouteName": "/health", "httpStatusClass": "2xx"},
+		},
+		{
+			name:      "invalid status code",
+			spanAttrs: map[string]interface{}{"http.status_code": int64(0), "http.method": "GET"},
+			expected:  map[string]interface{}{},
+		},
+		{
+			name:      "old db names",
+			spanAttrs: map[string]interface{}{"db.system": "postgresql", "db.operation": "SELECT"},
+			expected:  map[string]interface{}{"dbOperation": "postgresql SELECT"},
+		},
+		{
+			name:      "new db names",
+			spanAttrs: map[string]interface{}{"db.system.name": "redis", "db.operation.name": "GET"},
+			expected:  map[string]interface{}{"dbOperation": "redis GET"},
+		},
+		{
+			name:      "db system only",
+			spanAttrs: map[string]interface{}{"db.system": "mongodb"},
+			expected:  map[string]interface{}{"dbOperation": "mongodb"},
+		},
+		{
+			name:      "grpc ok",
+			spanAttrs: map[string]interface{}{"rpc.grpc.status_code": int64(0)},
+			expected:  map[string]interface{}{"rpcError": false},
+		},
+		{
+			name:      "grpc error",
+			spanAttrs: map[string]interface{}{"rpc.grpc.status_code": int64(14)},
+			expected:  map[string]interface{}{"rpcError": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := map[string]interface{}{"spanAttributes": tt.spanAttrs}
+			AddSemconvFields(attrs)
+			delete(attrs, "spanAttributes")
+			assert.Equal(t, tt.expected, attrs)
+		})
+	}
+}
+
+func TestTranslateTraceReqWithSemconvFields(t *testing.T) {
+	span := newTestSpan("GET")
+	span.Attributes = []*common.KeyValue{
+		stringAttr("http.request.method", "GET"),
+		stringAttr("http.route", "/users/{id}"),
+		{Key: "http.response.status_code", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 500}}},
+	}
+	req := newTestRequest(span)
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"}, WithSemconvFields())
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "GET /users/{id}", attrs["httpRouteName"])
+	assert.Equal(t, "5xx", attrs["httpStatusClass"])
+
+	result, err = TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "httpRouteName")
+}
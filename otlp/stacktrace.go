@@ -0,0 +1,198 @@
+package otlp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Runtimes recognised by ParseStacktrace
+const (
+	RuntimeGo     = "go"
+	RuntimeJava   = "java"
+	RuntimePython = "python"
+	RuntimeNode   = "node"
+)
+
+var (
+	// <tab>/app/main.go:42 +0x1d
+	goFilePattern = regexp.MustCompile(`^\s+(\S+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+	// at com.example.Foo.bar(Foo.java:42)
+	javaFramePattern = regexp.MustCompile(`^\s*at ([^\s(]+)\(([^:)]*)(?::(\d+))?\)$`)
+	// File "/app/app.py", line 42, in handler
+	pythonFramePattern = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+), in (.+)$`)
+	// at handler (/app/index.js:42:13) or at /app/index.js:42:13
+	nodeFramePattern = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+?):(\d+):\d+\)?$`)
+
+	// hex addresses and the numbered suffixes of generated classes and lambdas
+	frameNoisePattern = regexp.MustCompile(`0x[0-9a-fA-F]+|\$\$?Lambda\$\d+|\$\d+`)
+)
+
+// StackFrame is one normalized frame of a stack trace
+// Module is the package, class or file the function belongs to and File is the file name without its directory
+type StackFrame struct {
+	Module   string `json:"module,omitempty"`
+	Function string `json:"function"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// Stacktrace is the result of parsing a stack trace, Runtime is empty when the format was not recognised
+type Stacktrace struct {
+	Runtime string
+	Frames  []StackFrame
+}
+
+// ParseStacktrace extracts the frames of a Go panic, Java, Python or Node/V8 stack trace
+func ParseStacktrace(stacktrace string) Stacktrace {
+	var parsed Stacktrace
+	lines := strings.Split(strings.ReplaceAll(stacktrace, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// go frames are a function line followed by an indented file line
+		if i+1 < len(lines) && isRuntime(parsed, RuntimeGo) {
+			if m := goFilePattern.FindStringSubmatch(lines[i+1]); m != nil {
+				if name, ok := goFunctionName(line); ok {
+					module, function := splitGoFunction(name)
+					parsed.add(RuntimeGo, StackFrame{Module: module, Function: function, File: m[1], Line: atoi(m[2])})
+					i++
+					continue
+				}
+			}
+		}
+		if m := pythonFramePattern.FindStringSubmatch(line); m != nil && isRuntime(parsed, RuntimePython) {
+			parsed.add(RuntimePython, StackFrame{Function: m[3], File: m[1], Line: atoi(m[2])})
+			continue
+		}
+		if m := nodeFramePattern.FindStringSubmatch(line); m != nil && isRuntime(parsed, RuntimeNode) {
+			parsed.add(RuntimeNode, StackFrame{Function: nodeFunction(m[1]), File: m[2], Line: atoi(m[3])})
+			continue
+		}
+		if m := javaFramePattern.FindStringSubmatch(line); m != nil && isRuntime(parsed, RuntimeJava) {
+			module, function := splitLast(m[1], ".")
+			parsed.add(RuntimeJava, StackFrame{Module: module, Function: function, File: m[2], Line: atoi(m[3])})
+		}
+	}
+	return parsed
+}
+
+// isRuntime reports whether frames of runtime can be added, a trace only has frames of one runtime
+func isRuntime(parsed Stacktrace, runtime string) bool {
+	return parsed.Runtime == "" || parsed.Runtime == runtime
+}
+
+func (s *Stacktrace) add(runtime string, frame StackFrame) {
+	s.Runtime = runtime
+	frame.Module = frameNoisePattern.ReplaceAllString(frame.Module, "")
+	frame.Function = frameNoisePattern.ReplaceAllString(frame.Function, "")
+	frame.File = normalizeFramePath(frame.File)
+	s.Frames = append(s.Frames, frame)
+}
+
+// Fingerprint hashes the runtime and the module, function and file of every frame
+// Line numbers are left out so the fingerprint survives unrelated changes to the same files
+func (s Stacktrace) Fingerprint(exceptionType string) string {
+	h := sha256.New()
+	h.Write([]byte(s.Runtime))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(exceptionType))
+	for _, frame := range s.Frames {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(frame.Module + "|" + frame.Function + "|" + frame.File))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:stacktraceFingerprintBytes])
+}
+
+// AddStacktraceFields parses exceptionStacktrace and adds exceptionRuntime and exceptionFrames,
+// a JSON array of the frames, to attrs
+// The OTLP trace translator runs it when configured with WithStacktraceFrames, other translators such
+// as a logs translator can call it on the events they produce
+func AddStacktraceFields(attrs map[string]interface{}) {
+	stacktrace, _ := attrs["exceptionStacktrace"].(string)
+	if stacktrace == "" {
+		return
+	}
+	parsed := ParseStacktrace(stacktrace)
+	if len(parsed.Frames) == 0 {
+		return
+	}
+	attrs["exceptionRuntime"] = parsed.Runtime
+	if bytes, err := json.Marshal(parsed.Frames); err == nil {
+		attrs["exceptionFrames"] = string(bytes)
+	}
+}
+
+// normalizeFramePath drops the directory, URL scheme and query of a frame's file
+// so traces from different hosts, checkouts and bundles match
+func normalizeFramePath(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		path = path[i+1:]
+	}
+	return path
+}
+
+// goFunctionName returns the function of a Go frame such as main.(*T).handle(0xc000012345)
+// or created by main.main in goroutine 1, without its arguments
+func goFunctionName(line string) (string, bool) {
+	name := strings.TrimPrefix(strings.TrimSpace(line), "created by ")
+	if i := strings.Index(name, " in goroutine "); i >= 0 {
+		name = name[:i]
+	}
+	if strings.HasSuffix(name, ")") {
+		// the arguments are the last parenthesised group, receivers such as (*T) come before it
+		depth := 0
+		for i := len(name) - 1; i >= 0; i-- {
+			switch name[i] {
+			case ')':
+				depth++
+			case '(':
+				depth--
+			}
+			if depth == 0 {
+				name = name[:i]
+				break
+			}
+		}
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", false
+	}
+	return name, true
+}
+
+// splitGoFunction splits github.com/org/pkg.(*Type).Method into the package and the function
+func splitGoFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+func splitLast(name string, sep string) (string, string) {
+	if i := strings.LastIndex(name, sep); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+func nodeFunction(name string) string {
+	name = strings.TrimPrefix(name, "async ")
+	name = strings.TrimPrefix(name, "new ")
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,119 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStacktrace(t *testing.T) {
+	tests := []struct {
+		name       string
+		stacktrace string
+		expected   Stacktrace
+	}{
+		{
+			name: "go",
+			stacktrace: `panic: boom
+
+goroutine 1 [running]:
+github.com/org/app/server.(*Server).handle(0xc000012345, {0x1, 0x2})
+	/home/ci/go/src/github.com/org/app/server/server.go:42 +0x1d
+main.main()
+	/app/main.go:10 +0x25
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3086 +0x5cb`,
+			expected: Stacktrace{Runtime: RuntimeGo, Frames: []StackFrame{
+				{Module: "github.com/org/app/server", Function: "(*Server).handle", File: "server.go", Line: 42},
+				{Module: "main", Function: "main", File: "main.go", Line: 10},
+				{Module: "net/http", Function: "(*Server).Serve", File: "server.go", Line: 3086},
+			}},
+		},
+		{
+			name: "java",
+			stacktrace: `java.lang.IllegalStateException: boom
+	at com.example.Service$1.run(Service.java:42)
+	at com.example.Service.lambda$handle$0(Service.java:17)
+	at java.base/jdk.internal.reflect.NativeMethodAccessorImpl.invoke0(Native Method)
+Caused by: java.io.IOException: closed
+	... 3 more`,
+			expected: Stacktrace{Runtime: RuntimeJava, Frames: []StackFrame{
+				{Module: "com.example.Service", Function: "run", File: "Service.java", Line: 42},
+				{Module: "com.example.Service", Function: "lambda$handle", File: "Service.java", Line: 17},
+				{Module: "java.base/jdk.internal.reflect.NativeMethodAccessorImpl", Function: "invoke0", File: "Native Method"},
+			}},
+		},
+		{
+			name: "python",
+			stacktrace: `Traceback (most recent call last):
+  File "/srv/app/venv/lib/python3.11/site-packages/flask/app.py", line 1484, in full_dispatch_request
+    rv = self.dispatch_request()
+  File "/srv/app/views.py", line 12, in index
+    raise ValueError("bad value")
+ValueError: bad value`,
+			expected: Stacktrace{Runtime: RuntimePython, Frames: []StackFrame{
+				{Function: "full_dispatch_request", File: "app.py", Line: 1484},
+				{Function: "index", File: "views.py", Line: 12},
+			}},
+		},
+		{
+			name: "node",
+			stacktrace: `TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (/app/src/users.js:42:13)
+    at async Router.handle (file:///app/node_modules/router/index.mjs:10:5)
+    at new Server (/app/server.js:3:1)
+    at /app/index.js:7:21`,
+			expected: Stacktrace{Runtime: RuntimeNode, Frames: []StackFrame{
+				{Function: "getUser", File: "users.js", Line: 42},
+				{Function: "Router.handle", File: "index.mjs", Line: 10},
+				{Function: "Server", File: "server.js", Line: 3},
+				{Function: "<anonymous>", File: "index.js", Line: 7},
+			}},
+		},
+		{
+			name:       "unknown",
+			stacktrace: "something went wrong\nsomewhere",
+			expected:   Stacktrace{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseStacktrace(tt.stacktrace))
+		})
+	}
+}
+
+func TestStacktraceFingerprintIgnoresLinesAndPaths(t *testing.T) {
+	built := "Error: boom\n    at getUser (/build/1234/src/users.js:42:13)\n    at main (/build/1234/index.js:7:21)"
+	rebuilt := "Error: boom\n    at getUser (/build/5678/src/users.js:48:2)\n    at main (/build/5678/index.js:9:21)"
+	assert.Equal(t, StacktraceFingerprint("Error", built), StacktraceFingerprint("Error", rebuilt))
+
+	other := "Error: boom\n    at getOrder (/build/1234/src/orders.js:42:13)\n    at main (/build/1234/index.js:7:21)"
+	assert.NotEqual(t, StacktraceFingerprint("Error", built), StacktraceFingerprint("Error", other))
+}
+
+func TestAddStacktraceFields(t *testing.T) {
+	attrs := map[string]interface{}{"exceptionStacktrace": "Error: boom\n    at getUser (/app/users.js:42:13)"}
+	AddStacktraceFields(attrs)
+	assert.Equal(t, RuntimeNode, attrs["exceptionRuntime"])
+	assert.Equal(t, `[{"function":"getUser","file":"users.js","line":42}]`, attrs["exceptionFrames"])
+
+	attrs = map[string]interface{}{"exceptionStacktrace": "not a stack trace"}
+	AddStacktraceFields(attrs)
+	assert.NotContains(t, attrs, "exceptionFrames")
+}
+
+func TestTranslateTraceReqWithStacktraceFrames(t *testing.T) {
+	req := newTestRequest(newExceptionSpan(nil, newExceptionEvent("*errors.errorString", "boom", goStacktrace)))
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"}, WithStacktraceFrames())
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, RuntimeGo, attrs["exceptionRuntime"])
+	assert.Equal(t, `[{"module":"main","function":"handler","file":"main.go","line":42},{"module":"main","function":"main","file":"main.go","line":10}]`, attrs["exceptionFrames"])
+
+	result, err = TranslateTraceReq(req, RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "exceptionFrames")
+}
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/klauspost/compress/zstd"
@@ -28,17 +29,25 @@ const (
 // TranslateTraceRequestResult represents an OTLP trace request translated into Opsramp-friendly structure
 // RequestSize is total byte size of the entire OTLP request
 // Batches represent events grouped by their target dataset
+// AcceptedSpans and RejectedSpans count the spans translated and those dropped because they failed
+// validation or were dropped by a processor
+// Warnings are human-readable notes about spans or attributes that were not translated as sent
 type TranslateTraceRequestResult struct {
-	RequestSize int
-	Batches     []Batch
+	RequestSize   int
+	Batches       []Batch
+	AcceptedSpans int
+	RejectedSpans int
+	Warnings      []string
 }
 
 // Batch represents Opsramp events grouped by their target dataset
 // SizeBytes is the total byte size of the OTLP structure that represents this batch
+// Truncated is set when the sender reported dropping attributes, events or links, eg because of SDK limits
 type Batch struct {
 	Dataset   string
 	SizeBytes int
 	Events    []Event
+	Truncated bool
 }
 
 // Event represents a single Opsramp event
@@ -209,25 +218,48 @@ type Event struct {
 //	}, nil
 //}
 
-func TranslateTraceReqFromReader(body io.ReadCloser, ri RequestInfo) (*TranslateTraceRequestResult, error) {
-	/*if err := ri.ValidateTracesHeaders(); err != nil {
+// TranslateTraceReqFromReader translates an OTLP/HTTP request body into Opsramp-friendly structure
+// RequestInfo is the parsed information from the HTTP headers
+func TranslateTraceReqFromReader(body io.ReadCloser, ri RequestInfo, opts ...TranslateOption) (*TranslateTraceRequestResult, error) {
+	cfg := newTranslateConfig(opts)
+	if err := ri.ValidateTracesHeaders(); err != nil {
+		cfg.metrics.RecordParseFailure(signalTraces, errorTypeLabel(err))
+		cfg.metrics.RecordRequest(signalTraces, resultFailure)
 		return nil, err
-	}*/
+	}
 	fmt.Println("inside TranslateTraceReqFromReader")
-	request, err := parseOTLPBody(body, ri.ContentEncoding)
+	start := time.Now()
+	request, err := parseOTLPBody(body, ri.ContentEncoding, cfg.metrics)
 	if err != nil {
+		cfg.metrics.RecordParseFailure(signalTraces, errorTypeLabel(ErrFailedParseBody))
+		cfg.metrics.RecordRequest(signalTraces, resultFailure)
 		return nil, ErrFailedParseBody
 	}
-	return TranslateTraceReq(request, ri)
+	return translateTraceReq(request, ri, cfg, start)
+}
+
+// TranslateTraceReq translates an OTLP/gRPC request into Opsramp-friendly structure
+// RequestInfo is the parsed information from the gRPC metadata
+// gRPC decompresses requests before they are decoded, so both byte counts are the size of the request
+func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo, opts ...TranslateOption) (*TranslateTraceRequestResult, error) {
+	cfg := newTranslateConfig(opts)
+	size := proto.Size(request)
+	cfg.metrics.RecordBytesReceived(signalTraces, size, size)
+	return translateTraceReq(request, ri, cfg, time.Now())
 }
 
-func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo) (*TranslateTraceRequestResult, error) {
+func translateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo, cfg *translateConfig, start time.Time) (*TranslateTraceRequestResult, error) {
 
 	/*if err := ri.ValidateTracesHeaders(); err != nil {
 		return nil, err
 	}*/
 
 	var batches []Batch
+	var numSpans, numSpanEvents, numLinks, sdkDroppedAttrs, rejectedSpans int
+	var attrCounts attributeCounts
+	rejectedReasons := make(map[string]int)
+	keptReasons := make(map[string]int)
+	requestAttrs := requestAttributes(ri, cfg.requestFields)
 	//isLegacy := isLegacy(ri.ApiKey)
 	fmt.Println("inside TranslateTraceReq")
 	for _, resourceSpan := range request.ResourceSpans {
@@ -236,55 +268,60 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 		traceAttributes := make(map[string]map[string]interface{})
 		traceAttributes["resourceAttributes"] = make(map[string]interface{})
 
+		var resourceDropped int
 		if resourceSpan.Resource != nil {
-			addAttributesToMap(traceAttributes["resourceAttributes"], resourceSpan.Resource.Attributes)
+			attrCounts.add(addAttributesToMap(traceAttributes["resourceAttributes"], resourceSpan.Resource.Attributes))
+			resourceDropped = int(resourceSpan.Resource.DroppedAttributesCount)
+			sdkDroppedAttrs += resourceDropped
+		}
+		truncated := resourceDropped > 0
+		if len(resourceSpan.SchemaUrl) > 0 {
+			traceAttributes["resourceAttributes"]["resource.schema_url"] = resourceSpan.SchemaUrl
 		}
 
-		var dataset string
-		dataset = ri.Dataset
-		//if ri.Dataset != ""{
-		//	dataset = ri.Dataset
-		//	fmt.Println("dataset is",dataset+ "and"+ ri.Dataset)
-		//} else {
-		//	fmt.Println("no dataset so default one choosing")
-		//	if resourceSpan.Resource == nil {
-		//		dataset = defaultServiceName
-		//		fmt.Println("dataset is",defaultServiceName)
-		//	} else {
-		//		serviceName, ok := traceAttributes["resourceAttributes"]["service.name"].(string)
-		//		if !ok || serviceName == "" {
-		//			dataset = defaultServiceName
-		//			fmt.Println("service name not got",dataset,defaultServiceName)
-		//		} else {
-		//			if strings.HasPrefix(serviceName, "unknown_service") {
-		//				fmt.Println("service name got with prefix",serviceName,defaultServiceName)
-		//				dataset = defaultServiceName
-		//			} else {
-		//				dataset = serviceName
-		//				fmt.Println("service name got",dataset)
-		//			}
-		//		}
-		//	}
-		//}
-
-		for _, librarySpan := range resourceSpan.InstrumentationLibrarySpans {
-			library := librarySpan.InstrumentationLibrary
-			if library != nil {
-				if len(library.Name) > 0 {
-					//resourceAttrs["library.name"] = library.Name
-					traceAttributes["resourceAttributes"]["library.name"] = library.Name
-				}
-				if len(library.Version) > 0 {
-					//resourceAttrs["library.version"] = library.Version
-					traceAttributes["resourceAttributes"]["library.version"] = library.Version
-				}
+		dataset := ri.Dataset
+		if dataset == "" {
+			dataset = getDataset(traceAttributes["resourceAttributes"])
+		}
+
+		// scope spans include those sent in the deprecated instrumentation library field
+		for _, librarySpan := range getScopeSpans(resourceSpan) {
+			// the resource map is shared by every scope, scope metadata goes on a copy
+			scopeAttrs, scopeCounts := addScopeAttributes(traceAttributes["resourceAttributes"], librarySpan)
+			attrCounts.add(scopeCounts)
+			var scopeDropped int
+			if librarySpan.Scope != nil {
+				scopeDropped = int(librarySpan.Scope.DroppedAttributesCount)
+				sdkDroppedAttrs += scopeDropped
 			}
+			truncated = truncated || scopeDropped > 0
 
 			for _, span := range librarySpan.GetSpans() {
 
 				traceAttributes["spanAttributes"] = make(map[string]interface{})
 				traceAttributes["eventAttributes"] = make(map[string]interface{})
 
+				sdkDroppedAttrs += int(span.DroppedAttributesCount)
+
+				startTime, endTime := span.StartTimeUnixNano, span.EndTimeUnixNano
+				invalidReason := validateSpan(span)
+				if invalidReason != "" && cfg.invalidSpanPolicy != InvalidSpanKeep {
+					if cfg.invalidSpanPolicy == InvalidSpanDrop || invalidReason != InvalidReasonEndBeforeStart {
+						rejectedSpans++
+						rejectedReasons[invalidReason]++
+						continue
+					}
+					endTime = startTime
+					invalidReason = ""
+				}
+				if invalidReason != "" {
+					keptReasons[invalidReason]++
+				}
+				// rejected spans are not counted as translated items
+				numSpans++
+				numSpanEvents += len(span.Events)
+				numLinks += len(span.Links)
+
 				traceID := BytesToTraceID(span.TraceId)
 				spanID := hex.EncodeToString(span.SpanId)
 
@@ -295,9 +332,9 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 					"type":          spanKind,
 					"spanKind":      spanKind,
 					"spanName":      span.Name,
-					"durationMs":    float64(span.EndTimeUnixNano-span.StartTimeUnixNano) / float64(time.Millisecond),
-					"startTime":     int64(span.StartTimeUnixNano),
-					"endTime":       int64(span.EndTimeUnixNano),
+					"durationMs":    float64(int64(endTime)-int64(startTime)) / float64(time.Millisecond),
+					"startTime":     int64(startTime),
+					"endTime":       int64(endTime),
 					"statusCode":    getSpanStatusCode(span.Status),
 					"spanNumLinks":  len(span.Links),
 					"spanNumEvents": len(span.Events),
@@ -305,18 +342,38 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 				if span.ParentSpanId != nil {
 					eventAttrs["traceParentID"] = hex.EncodeToString(span.ParentSpanId)
 				}
+				if span.Flags != 0 {
+					eventAttrs["spanFlags"] = int64(span.Flags)
+				}
+				if len(span.TraceState) > 0 {
+					eventAttrs["traceState"] = span.TraceState
+				}
+				if invalidReason != "" {
+					eventAttrs["spanInvalidReason"] = invalidReason
+				}
+				if addDroppedCounts(eventAttrs, span, resourceDropped, scopeDropped) {
+					truncated = true
+				}
 
 				if getSpanStatusCode(span.Status) == trace.Status_STATUS_CODE_ERROR {
 					eventAttrs["error"] = true
 				} else {
 					eventAttrs["error"] = false
 				}
+				// spans that recorded an exception are errors unless their status says otherwise
+				if addExceptionFields(eventAttrs, span.Events) && cfg.errorOnException &&
+					getSpanStatusCode(span.Status) == trace.Status_STATUS_CODE_UNSET {
+					eventAttrs["error"] = true
+				}
+				if cfg.stacktraceFrames {
+					AddStacktraceFields(eventAttrs)
+				}
 
 				if span.Status != nil && len(span.Status.Message) > 0 {
 					eventAttrs["statusMessage"] = span.Status.Message
 				}
 				if span.Attributes != nil {
-					addAttributesToMap(traceAttributes["spanAttributes"], span.Attributes)
+					attrCounts.add(addAttributesToMap(traceAttributes["spanAttributes"], span.Attributes))
 				}
 
 				// copy resource attributes to event attributes
@@ -328,7 +385,15 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 				/*for k, v := range traceAttributes["resource.attributes"] {
 					eventAttrs[k] = v
 				}*/
-				eventAttrs["resourceAttributes"] = traceAttributes["resourceAttributes"]
+				eventAttrs["resourceAttributes"] = scopeAttrs
+				if requestAttrs != nil {
+					// each event gets its own copy so processors can change one without affecting the others
+					attrs := make(map[string]interface{}, len(requestAttrs))
+					for k, v := range requestAttrs {
+						attrs[k] = v
+					}
+					eventAttrs["requestAttributes"] = attrs
+				}
 
 				//Copy span attributes
 				/*for k, v := range traceAttributes["span.attributes"] {
@@ -339,7 +404,7 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 				//Check for event attributes and add them
 				for _, sevent := range span.Events {
 					if sevent.Attributes != nil {
-						addAttributesToMap(traceAttributes["eventAttributes"], sevent.Attributes)
+						attrCounts.add(addAttributesToMap(traceAttributes["eventAttributes"], sevent.Attributes))
 					}
 				}
 				eventAttrs["eventAttributes"] = traceAttributes["eventAttributes"]
@@ -348,11 +413,17 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 				// Now we need to wrap the eventAttrs in an event so we can specify the timestamp
 				// which is the StartTime as a time.Time object
 				timestamp := time.Unix(0, int64(span.StartTimeUnixNano)).UTC()
-				events = append(events, Event{
+				event := Event{
 					Attributes: eventAttrs,
 					Timestamp:  timestamp,
-					SampleRate: getSampleRate(eventAttrs),
-				})
+					SampleRate: getSampleRate(traceAttributes["spanAttributes"]),
+				}
+				cfg.schemaMigrator.Migrate(&event)
+				if cfg.semconvFields {
+					AddSemconvFields(event.Attributes)
+				}
+				cfg.transforms.Apply(&event)
+				events = append(events, event)
 
 				//for _, sevent := range span.Events {
 				//	timestamp := time.Unix(0, int64(sevent.TimeUnixNano)).UTC()
@@ -409,14 +480,56 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 			Dataset:   dataset,
 			SizeBytes: proto.Size(resourceSpan),
 			Events:    events,
+			Truncated: truncated,
 		})
 	}
+
+	dropped, err := RunProcessors(cfg.ctx, ri, batches, cfg.processors...)
+	if err != nil {
+		cfg.metrics.RecordRequest(signalTraces, resultFailure)
+		return nil, err
+	}
+	// spans whose events a processor dropped are rejected, events processors add are not spans
+	if dropped > 0 {
+		numSpans -= dropped
+		rejectedSpans += dropped
+		rejectedReasons[rejectReasonProcessor] += dropped
+	}
+
+	cfg.metrics.RecordItems(signalTraces, eventKindSpan, numSpans)
+	cfg.metrics.RecordItems(signalTraces, eventKindSpanEvent, numSpanEvents)
+	cfg.metrics.RecordItems(signalTraces, eventKindLink, numLinks)
+	cfg.metrics.RecordAttributesDropped(signalTraces, dropReasonInvalid, attrCounts.dropped)
+	cfg.metrics.RecordAttributesDropped(signalTraces, dropReasonSDKLimit, sdkDroppedAttrs)
+	cfg.metrics.RecordAttributesTruncated(signalTraces, attrCounts.truncated)
+	cfg.metrics.RecordRequest(signalTraces, resultSuccess)
+	cfg.metrics.RecordTranslationLatency(signalTraces, time.Since(start))
+
 	return &TranslateTraceRequestResult{
-		RequestSize: proto.Size(request),
-		Batches:     batches,
+		RequestSize:   proto.Size(request),
+		Batches:       batches,
+		AcceptedSpans: numSpans,
+		RejectedSpans: rejectedSpans,
+		Warnings:      translationWarnings(rejectedReasons, keptReasons, attrCounts.dropped),
 	}, nil
 }
 
+// translationWarnings describes the spans rejected or kept by the invalid span policy and
+// the attributes ignored because they had no key or value
+func translationWarnings(rejectedReasons map[string]int, keptReasons map[string]int, droppedAttrs int) []string {
+	var warnings []string
+	for _, reason := range sortedKeys(rejectedReasons) {
+		warnings = append(warnings, fmt.Sprintf("%d spans rejected: %s", rejectedReasons[reason], reason))
+	}
+	for _, reason := range sortedKeys(keptReasons) {
+		warnings = append(warnings, fmt.Sprintf("%d invalid spans accepted: %s", keptReasons[reason], reason))
+	}
+	if droppedAttrs > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d attributes dropped: missing key or value", droppedAttrs))
+	}
+	return warnings
+}
+
 //func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo) (*TranslateTraceRequestResult, error) {
 //
 //	//if err := ri.ValidateTracesHeaders(); err != nil {
@@ -582,6 +695,16 @@ func TranslateTraceReq(request *collectorTrace.ExportTraceServiceRequest, ri Req
 //	}, nil
 //}
 
+// getDataset returns the dataset for spans without a dataset header, the service name of their resource
+// Missing, empty or non-string service names and the SDK default unknown_service:<process> use unknown_service
+func getDataset(resourceAttrs map[string]interface{}) string {
+	serviceName, ok := resourceAttrs["service.name"].(string)
+	if !ok || serviceName == "" || strings.HasPrefix(serviceName, defaultServiceName) {
+		return defaultServiceName
+	}
+	return serviceName
+}
+
 func getSpanKind(kind trace.Span_SpanKind) string {
 	switch kind {
 	case trace.Span_SPAN_KIND_CLIENT:
@@ -636,6 +759,54 @@ func shouldTrimTraceId(traceID []byte) bool {
 	return true
 }
 
+// addScopeAttributes returns the resource attributes with the scope name, version, attributes and
+// schema URL added. resourceAttrs is never modified, it is copied when the scope has metadata
+func addScopeAttributes(resourceAttrs map[string]interface{}, scopeSpans *trace.ScopeSpans) (map[string]interface{}, attributeCounts) {
+	scope := scopeSpans.Scope
+	if len(scopeSpans.SchemaUrl) == 0 && (scope == nil || len(scope.Name) == 0 && len(scope.Version) == 0 && len(scope.Attributes) == 0) {
+		return resourceAttrs, attributeCounts{}
+	}
+
+	attrs := make(map[string]interface{}, len(resourceAttrs)+3)
+	for k, v := range resourceAttrs {
+		attrs[k] = v
+	}
+	var counts attributeCounts
+	if scope != nil {
+		if len(scope.Name) > 0 {
+			attrs["library.name"] = scope.Name
+		}
+		if len(scope.Version) > 0 {
+			attrs["library.version"] = scope.Version
+		}
+		counts = addAttributesToMap(attrs, scope.Attributes)
+	}
+	if len(scopeSpans.SchemaUrl) > 0 {
+		attrs["library.schema_url"] = scopeSpans.SchemaUrl
+	}
+	return attrs, counts
+}
+
+// addDroppedCounts adds the non-zero counts of attributes, events and links the sender dropped
+// from the span, its scope and its resource, returning true if anything was dropped
+func addDroppedCounts(eventAttrs map[string]interface{}, span *trace.Span, resourceDropped int, scopeDropped int) bool {
+	counts := map[string]int{
+		"resourceDroppedAttributes": resourceDropped,
+		"libraryDroppedAttributes":  scopeDropped,
+		"spanDroppedAttributes":     int(span.DroppedAttributesCount),
+		"spanDroppedEvents":         int(span.DroppedEventsCount),
+		"spanDroppedLinks":          int(span.DroppedLinksCount),
+	}
+	dropped := false
+	for k, v := range counts {
+		if v > 0 {
+			eventAttrs[k] = v
+			dropped = true
+		}
+	}
+	return dropped
+}
+
 // getSpanStatusCode checks the value of both the deprecated code and code fields
 // on the span status and using the rules specified in the backward compatibility
 // notes in the protobuf definitions. See:
@@ -646,19 +817,49 @@ func getSpanStatusCode(status *trace.Status) trace.Status_StatusCode {
 		return trace.Status_STATUS_CODE_UNSET
 	}
 	if status.Code == trace.Status_STATUS_CODE_UNSET {
-		if status.DeprecatedCode == trace.Status_DEPRECATED_STATUS_CODE_OK {
-			return trace.Status_STATUS_CODE_UNSET
-		}
-		return trace.Status_STATUS_CODE_ERROR
+		return StatusCodeFromLegacy(getDeprecatedStatusCode(status))
 	}
 	return status.Code
 }
 
-func parseOTLPBody(body io.ReadCloser, contentEncoding string) (request *collectorTrace.ExportTraceServiceRequest, err error) {
+// StatusCodeFromLegacy maps a legacy status code, a gRPC code as used by the deprecated OTLP
+// code and OpenCensus, onto the OTLP status code. OK is unset, any other code is an error
+func StatusCodeFromLegacy(code int32) trace.Status_StatusCode {
+	if code == deprecatedStatusCodeOK {
+		return trace.Status_STATUS_CODE_UNSET
+	}
+	return trace.Status_STATUS_CODE_ERROR
+}
+
+func parseOTLPBody(body io.ReadCloser, contentEncoding string, metrics MetricsRecorder) (request *collectorTrace.ExportTraceServiceRequest, err error) {
+	bodyBytes, bytes, err := readBody(body, contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordBytesReceived(signalTraces, len(bodyBytes), len(bytes))
+
+	request = &collectorTrace.ExportTraceServiceRequest{}
+	err = proto.Unmarshal(bytes, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ReadBody reads a request body, decompressing it according to the content-encoding
+// Supported encodings are gzip and zstd, any other value returns the body as is
+func ReadBody(body io.ReadCloser, contentEncoding string) ([]byte, error) {
+	_, bytes, err := readBody(body, contentEncoding)
+	return bytes, err
+}
+
+// readBody returns both the raw and the decompressed body
+func readBody(body io.ReadCloser, contentEncoding string) ([]byte, []byte, error) {
 	defer body.Close()
 	bodyBytes, err := ioutil.ReadAll(body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	bodyReader := bytes.NewReader(bodyBytes)
 
@@ -666,17 +867,17 @@ func parseOTLPBody(body io.ReadCloser, contentEncoding string) (request *collect
 	switch contentEncoding {
 	case "gzip":
 		gzipReader, err := gzip.NewReader(bodyReader)
-		defer gzipReader.Close()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		defer gzipReader.Close()
 		reader = gzipReader
 	case "zstd":
 		zstdReader, err := zstd.NewReader(bodyReader)
-		defer zstdReader.Close()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		defer zstdReader.Close()
 		reader = zstdReader
 	default:
 		reader = bodyReader
@@ -684,16 +885,9 @@ func parseOTLPBody(body io.ReadCloser, contentEncoding string) (request *collect
 
 	bytes, err := ioutil.ReadAll(reader)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-
-	request = &collectorTrace.ExportTraceServiceRequest{}
-	err = proto.Unmarshal(bytes, request)
-	if err != nil {
-		return nil, err
-	}
-
-	return request, nil
+	return bodyBytes, bytes, nil
 }
 
 func getSampleRate(attrs map[string]interface{}) int32 {
@@ -51,7 +51,7 @@ func TestTranslateLegacyGrpcTraceRequest(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId:           traceID,
 					SpanId:            spanID,
@@ -98,7 +98,7 @@ func TestTranslateLegacyGrpcTraceRequest(t *testing.T) {
 		}},
 	}
 
-	result, err := TranslateTraceRequest(req, ri)
+	result, err := TranslateTraceReq(req, ri)
 	assert.Nil(t, err)
 	assert.Equal(t, proto.Size(req), result.RequestSize)
 	assert.Equal(t, 1, len(result.Batches))
@@ -106,48 +106,29 @@ func TestTranslateLegacyGrpcTraceRequest(t *testing.T) {
 	assert.Equal(t, "legacy-dataset", batch.Dataset)
 	assert.Equal(t, proto.Size(req.ResourceSpans[0]), batch.SizeBytes)
 	events := batch.Events
-	assert.Equal(t, 3, len(events))
+	assert.Equal(t, 1, len(events))
 
 	// span
 	ev := events[0]
+	resourceAttrs := ev.Attributes["resourceAttributes"].(map[string]interface{})
+	spanAttrs := ev.Attributes["spanAttributes"].(map[string]interface{})
 	assert.Equal(t, startTimestamp.Nanosecond(), ev.Timestamp.Nanosecond())
 	assert.Equal(t, int32(100), ev.SampleRate)
-	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.span_id"])
+	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["traceTraceID"])
+	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["traceSpanID"])
 	assert.Equal(t, "client", ev.Attributes["type"])
-	assert.Equal(t, "client", ev.Attributes["span.kind"])
-	assert.Equal(t, "test_span", ev.Attributes["name"])
-	assert.Equal(t, "my-service", ev.Attributes["service.name"])
-	assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["duration_ms"])
-	assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["status_code"])
-	assert.Equal(t, "span_attr_val", ev.Attributes["span_attr"])
-	assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-	assert.Equal(t, 1, ev.Attributes["span.num_links"])
-	assert.Equal(t, 1, ev.Attributes["span.num_events"])
-
-	// event
-	ev = events[1]
-	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-	assert.Equal(t, int32(0), ev.SampleRate)
-	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-	assert.Equal(t, "span_event", ev.Attributes["name"])
-	assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-	assert.Equal(t, "span_event", ev.Attributes["meta.annotation_type"])
-	assert.Equal(t, "span_event_attr_val", ev.Attributes["span_event_attr"])
-	assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-
-	// link
-	ev = events[2]
-	assert.Equal(t, startTimestamp.Nanosecond(), ev.Timestamp.Nanosecond())
-	assert.Equal(t, int32(0), ev.SampleRate)
-	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-	assert.Equal(t, BytesToTraceID(linkedTraceID), ev.Attributes["trace.link.trace_id"])
-	assert.Equal(t, hex.EncodeToString(linkedSpanID), ev.Attributes["trace.link.span_id"])
-	assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-	assert.Equal(t, "link", ev.Attributes["meta.annotation_type"])
-	assert.Equal(t, "span_link_attr_val", ev.Attributes["span_link_attr"])
-	assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
+	assert.Equal(t, "client", ev.Attributes["spanKind"])
+	assert.Equal(t, "test_span", ev.Attributes["spanName"])
+	assert.Equal(t, "my-service", resourceAttrs["service.name"])
+	assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["durationMs"])
+	assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["statusCode"])
+	assert.Equal(t, "span_attr_val", spanAttrs["span_attr"])
+	assert.Equal(t, "resource_attr_val", resourceAttrs["resource_attr"])
+	assert.Equal(t, 1, ev.Attributes["spanNumLinks"])
+	assert.Equal(t, 1, ev.Attributes["spanNumEvents"])
+
+	// span event attributes are merged into the span's event, links are only counted
+	assert.Equal(t, map[string]interface{}{"span_event_attr": "span_event_attr_val"}, ev.Attributes["eventAttributes"])
 }
 
 func TestTranslateGrpcTraceRequest(t *testing.T) {
@@ -160,8 +141,6 @@ func TestTranslateGrpcTraceRequest(t *testing.T) {
 	linkedSpanID := test.RandomBytes(8)
 
 	ri := RequestInfo{
-		ApiKey:      "abc123DEF456ghi789jklm",
-		Dataset:     "legacy-dataset",
 		ContentType: "application/protobuf",
 	}
 
@@ -180,7 +159,7 @@ func TestTranslateGrpcTraceRequest(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId:           traceID,
 					SpanId:            spanID,
@@ -227,7 +206,7 @@ func TestTranslateGrpcTraceRequest(t *testing.T) {
 		}},
 	}
 
-	result, err := TranslateTraceRequest(req, ri)
+	result, err := TranslateTraceReq(req, ri)
 	assert.Nil(t, err)
 	assert.Equal(t, proto.Size(req), result.RequestSize)
 	assert.Equal(t, 1, len(result.Batches))
@@ -235,53 +214,32 @@ func TestTranslateGrpcTraceRequest(t *testing.T) {
 	assert.Equal(t, "my-service", batch.Dataset)
 	assert.Equal(t, proto.Size(req.ResourceSpans[0]), batch.SizeBytes)
 	events := batch.Events
-	assert.Equal(t, 3, len(events))
+	assert.Equal(t, 1, len(events))
 
 	// span
 	ev := events[0]
+	resourceAttrs := ev.Attributes["resourceAttributes"].(map[string]interface{})
+	spanAttrs := ev.Attributes["spanAttributes"].(map[string]interface{})
 	assert.Equal(t, startTimestamp.Nanosecond(), ev.Timestamp.Nanosecond())
 	assert.Equal(t, int32(100), ev.SampleRate)
-	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.span_id"])
+	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["traceTraceID"])
+	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["traceSpanID"])
 	assert.Equal(t, "client", ev.Attributes["type"])
-	assert.Equal(t, "client", ev.Attributes["span.kind"])
-	assert.Equal(t, "test_span", ev.Attributes["name"])
-	assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["duration_ms"])
-	assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["status_code"])
-	assert.Equal(t, "span_attr_val", ev.Attributes["span_attr"])
-	assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-	assert.Equal(t, 1, ev.Attributes["span.num_links"])
-	assert.Equal(t, 1, ev.Attributes["span.num_events"])
-
-	// event
-	ev = events[1]
-	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-	assert.Equal(t, int32(0), ev.SampleRate)
-	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-	assert.Equal(t, "span_event", ev.Attributes["name"])
-	assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-	assert.Equal(t, "span_event", ev.Attributes["meta.annotation_type"])
-	assert.Equal(t, "span_event_attr_val", ev.Attributes["span_event_attr"])
-	assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-
-	// link
-	ev = events[2]
-	assert.Equal(t, startTimestamp.Nanosecond(), ev.Timestamp.Nanosecond())
-	assert.Equal(t, int32(0), ev.SampleRate)
-	assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-	assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-	assert.Equal(t, BytesToTraceID(linkedTraceID), ev.Attributes["trace.link.trace_id"])
-	assert.Equal(t, hex.EncodeToString(linkedSpanID), ev.Attributes["trace.link.span_id"])
-	assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-	assert.Equal(t, "link", ev.Attributes["meta.annotation_type"])
-	assert.Equal(t, "span_link_attr_val", ev.Attributes["span_link_attr"])
-	assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
+	assert.Equal(t, "client", ev.Attributes["spanKind"])
+	assert.Equal(t, "test_span", ev.Attributes["spanName"])
+	assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["durationMs"])
+	assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["statusCode"])
+	assert.Equal(t, "span_attr_val", spanAttrs["span_attr"])
+	assert.Equal(t, "resource_attr_val", resourceAttrs["resource_attr"])
+	assert.Equal(t, 1, ev.Attributes["spanNumLinks"])
+	assert.Equal(t, 1, ev.Attributes["spanNumEvents"])
+
+	// span event attributes are merged into the span's event, links are only counted
+	assert.Equal(t, map[string]interface{}{"span_event_attr": "span_event_attr_val"}, ev.Attributes["eventAttributes"])
 }
 
 func TestTranslateGrpcTraceRequestFromMultipleServices(t *testing.T) {
 	ri := RequestInfo{
-		ApiKey:      "abc123DEF456ghi789jklm",
-		Dataset:     "legacy-dataset",
 		ContentType: "application/protobuf",
 	}
 
@@ -295,7 +253,7 @@ func TestTranslateGrpcTraceRequestFromMultipleServices(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId: test.RandomBytes(16),
 					SpanId:  test.RandomBytes(8),
@@ -311,7 +269,7 @@ func TestTranslateGrpcTraceRequestFromMultipleServices(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId: test.RandomBytes(16),
 					SpanId:  test.RandomBytes(8),
@@ -321,7 +279,7 @@ func TestTranslateGrpcTraceRequestFromMultipleServices(t *testing.T) {
 		}},
 	}
 
-	result, err := TranslateTraceRequest(req, ri)
+	result, err := TranslateTraceReq(req, ri)
 	assert.Nil(t, err)
 	assert.Equal(t, proto.Size(req), result.RequestSize)
 	assert.Equal(t, 2, len(result.Batches))
@@ -336,8 +294,8 @@ func TestTranslateGrpcTraceRequestFromMultipleServices(t *testing.T) {
 	assert.Equal(t, 1, len(eventsA))
 	assert.Equal(t, 1, len(eventsB))
 
-	assert.Equal(t, "test_span_a", eventsA[0].Attributes["name"])
-	assert.Equal(t, "test_span_b", eventsB[0].Attributes["name"])
+	assert.Equal(t, "test_span_a", eventsA[0].Attributes["spanName"])
+	assert.Equal(t, "test_span_b", eventsB[0].Attributes["spanName"])
 }
 
 func TestTranslateLegacyHttpTraceRequest(t *testing.T) {
@@ -364,7 +322,7 @@ func TestTranslateLegacyHttpTraceRequest(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId:           traceID,
 					SpanId:            spanID,
@@ -424,13 +382,12 @@ func TestTranslateLegacyHttpTraceRequest(t *testing.T) {
 
 			body := io.NopCloser(strings.NewReader(buf.String()))
 			ri := RequestInfo{
-				ApiKey:          "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
 				Dataset:         "legacy-dataset",
 				ContentType:     "application/protobuf",
 				ContentEncoding: encoding,
 			}
 
-			result, err := TranslateTraceRequestFromReader(body, ri)
+			result, err := TranslateTraceReqFromReader(body, ri)
 			assert.Nil(t, err)
 			assert.Equal(t, proto.Size(req), result.RequestSize)
 			assert.Equal(t, 1, len(result.Batches))
@@ -438,42 +395,26 @@ func TestTranslateLegacyHttpTraceRequest(t *testing.T) {
 			assert.Equal(t, "legacy-dataset", batch.Dataset)
 			assert.Equal(t, proto.Size(req.ResourceSpans[0]), batch.SizeBytes)
 			events := batch.Events
-			assert.Equal(t, 3, len(events))
+			assert.Equal(t, 1, len(events))
 
 			// span
 			ev := events[0]
+			resourceAttrs := ev.Attributes["resourceAttributes"].(map[string]interface{})
+			spanAttrs := ev.Attributes["spanAttributes"].(map[string]interface{})
 			assert.Equal(t, startTimestamp.Nanosecond(), ev.Timestamp.Nanosecond())
-			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.span_id"])
+			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["traceTraceID"])
+			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["traceSpanID"])
 			assert.Equal(t, "client", ev.Attributes["type"])
-			assert.Equal(t, "client", ev.Attributes["span.kind"])
-			assert.Equal(t, "test_span", ev.Attributes["name"])
-			assert.Equal(t, "my-service", ev.Attributes["service.name"])
-			assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["duration_ms"])
-			assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["status_code"])
-			assert.Equal(t, "span_attr_val", ev.Attributes["span_attr"])
-			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-
-			// event
-			ev = events[1]
-			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-			assert.Equal(t, "span_event", ev.Attributes["name"])
-			assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-			assert.Equal(t, "span_event", ev.Attributes["meta.annotation_type"])
-			assert.Equal(t, "span_event_attr_val", ev.Attributes["span_event_attr"])
-			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-
-			// link
-			ev = events[2]
-			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-			assert.Equal(t, BytesToTraceID(linkedTraceID), ev.Attributes["trace.link.trace_id"])
-			assert.Equal(t, hex.EncodeToString(linkedSpanID), ev.Attributes["trace.link.span_id"])
-			assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-			assert.Equal(t, "link", ev.Attributes["meta.annotation_type"])
-			assert.Equal(t, "span_link_attr_val", ev.Attributes["span_link_attr"])
-			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
+			assert.Equal(t, "client", ev.Attributes["spanKind"])
+			assert.Equal(t, "test_span", ev.Attributes["spanName"])
+			assert.Equal(t, "my-service", resourceAttrs["service.name"])
+			assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["durationMs"])
+			assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["statusCode"])
+			assert.Equal(t, "span_attr_val", spanAttrs["span_attr"])
+			assert.Equal(t, "resource_attr_val", resourceAttrs["resource_attr"])
+
+			// span event attributes are merged into the span's event, links are only counted
+			assert.Equal(t, map[string]interface{}{"span_event_attr": "span_event_attr_val"}, ev.Attributes["eventAttributes"])
 		})
 	}
 }
@@ -502,7 +443,7 @@ func TestTranslateHttpTraceRequest(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId:           traceID,
 					SpanId:            spanID,
@@ -562,13 +503,11 @@ func TestTranslateHttpTraceRequest(t *testing.T) {
 
 			body := io.NopCloser(strings.NewReader(buf.String()))
 			ri := RequestInfo{
-				ApiKey:          "abc123DEF456ghi789jklm",
-				Dataset:         "legacy-dataset",
 				ContentType:     "application/protobuf",
 				ContentEncoding: encoding,
 			}
 
-			result, err := TranslateTraceRequestFromReader(body, ri)
+			result, err := TranslateTraceReqFromReader(body, ri)
 			assert.Nil(t, err)
 			assert.Equal(t, proto.Size(req), result.RequestSize)
 			assert.Equal(t, 1, len(result.Batches))
@@ -576,42 +515,26 @@ func TestTranslateHttpTraceRequest(t *testing.T) {
 			assert.Equal(t, "my-service", batch.Dataset)
 			assert.Equal(t, proto.Size(req.ResourceSpans[0]), batch.SizeBytes)
 			events := batch.Events
-			assert.Equal(t, 3, len(events))
+			assert.Equal(t, 1, len(events))
 
 			// span
 			ev := events[0]
+			resourceAttrs := ev.Attributes["resourceAttributes"].(map[string]interface{})
+			spanAttrs := ev.Attributes["spanAttributes"].(map[string]interface{})
 			assert.Equal(t, startTimestamp.Nanosecond(), ev.Timestamp.Nanosecond())
-			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.span_id"])
+			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["traceTraceID"])
+			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["traceSpanID"])
 			assert.Equal(t, "client", ev.Attributes["type"])
-			assert.Equal(t, "client", ev.Attributes["span.kind"])
-			assert.Equal(t, "test_span", ev.Attributes["name"])
-			assert.Equal(t, "my-service", ev.Attributes["service.name"])
-			assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["duration_ms"])
-			assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["status_code"])
-			assert.Equal(t, "span_attr_val", ev.Attributes["span_attr"])
-			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-
-			// event
-			ev = events[1]
-			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-			assert.Equal(t, "span_event", ev.Attributes["name"])
-			assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-			assert.Equal(t, "span_event", ev.Attributes["meta.annotation_type"])
-			assert.Equal(t, "span_event_attr_val", ev.Attributes["span_event_attr"])
-			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
-
-			// link
-			ev = events[2]
-			assert.Equal(t, BytesToTraceID(traceID), ev.Attributes["trace.trace_id"])
-			assert.Equal(t, hex.EncodeToString(spanID), ev.Attributes["trace.parent_id"])
-			assert.Equal(t, BytesToTraceID(linkedTraceID), ev.Attributes["trace.link.trace_id"])
-			assert.Equal(t, hex.EncodeToString(linkedSpanID), ev.Attributes["trace.link.span_id"])
-			assert.Equal(t, "test_span", ev.Attributes["parent_name"])
-			assert.Equal(t, "link", ev.Attributes["meta.annotation_type"])
-			assert.Equal(t, "span_link_attr_val", ev.Attributes["span_link_attr"])
-			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
+			assert.Equal(t, "client", ev.Attributes["spanKind"])
+			assert.Equal(t, "test_span", ev.Attributes["spanName"])
+			assert.Equal(t, "my-service", resourceAttrs["service.name"])
+			assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["durationMs"])
+			assert.Equal(t, trace.Status_STATUS_CODE_OK, ev.Attributes["statusCode"])
+			assert.Equal(t, "span_attr_val", spanAttrs["span_attr"])
+			assert.Equal(t, "resource_attr_val", resourceAttrs["resource_attr"])
+
+			// span event attributes are merged into the span's event, links are only counted
+			assert.Equal(t, map[string]interface{}{"span_event_attr": "span_event_attr_val"}, ev.Attributes["eventAttributes"])
 		})
 	}
 }
@@ -627,7 +550,7 @@ func TestTranslateHttpTraceRequestFromMultipleServices(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId: test.RandomBytes(16),
 					SpanId:  test.RandomBytes(8),
@@ -643,7 +566,7 @@ func TestTranslateHttpTraceRequestFromMultipleServices(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId: test.RandomBytes(16),
 					SpanId:  test.RandomBytes(8),
@@ -661,12 +584,10 @@ func TestTranslateHttpTraceRequestFromMultipleServices(t *testing.T) {
 
 	body := io.NopCloser(strings.NewReader(buf.String()))
 	ri := RequestInfo{
-		ApiKey:      "abc123DEF456ghi789jklm",
-		Dataset:     "legacy-dataset",
 		ContentType: "application/protobuf",
 	}
 
-	result, err := TranslateTraceRequestFromReader(body, ri)
+	result, err := TranslateTraceReqFromReader(body, ri)
 	assert.Nil(t, err)
 	assert.Equal(t, proto.Size(req), result.RequestSize)
 	assert.Equal(t, 2, len(result.Batches))
@@ -682,20 +603,19 @@ func TestTranslateHttpTraceRequestFromMultipleServices(t *testing.T) {
 	assert.Equal(t, 1, len(eventsA))
 	assert.Equal(t, 1, len(eventsB))
 
-	assert.Equal(t, "test_span_a", eventsA[0].Attributes["name"])
-	assert.Equal(t, "test_span_b", eventsB[0].Attributes["name"])
+	assert.Equal(t, "test_span_a", eventsA[0].Attributes["spanName"])
+	assert.Equal(t, "test_span_b", eventsB[0].Attributes["spanName"])
 }
 
 func TestInvalidContentTypeReturnsError(t *testing.T) {
 	bodyBytes, _ := proto.Marshal(&collectortrace.ExportTraceServiceRequest{})
 	body := io.NopCloser(bytes.NewReader(bodyBytes))
 	ri := RequestInfo{
-		ApiKey:      "apikey",
 		Dataset:     "dataset",
 		ContentType: "application/json",
 	}
 
-	result, err := TranslateTraceRequestFromReader(body, ri)
+	result, err := TranslateTraceReqFromReader(body, ri)
 	assert.Nil(t, result)
 	assert.Equal(t, ErrInvalidContentType, err)
 }
@@ -704,12 +624,11 @@ func TestInvalidBodyReturnsError(t *testing.T) {
 	bodyBytes := test.RandomBytes(10)
 	body := io.NopCloser(bytes.NewReader(bodyBytes))
 	ri := RequestInfo{
-		ApiKey:      "apikey",
 		Dataset:     "dataset",
 		ContentType: "application/protobuf",
 	}
 
-	result, err := TranslateTraceRequestFromReader(body, ri)
+	result, err := TranslateTraceReqFromReader(body, ri)
 	assert.Nil(t, result)
 	assert.Equal(t, ErrFailedParseBody, err)
 }
@@ -790,7 +709,7 @@ func TestMissingServiceNameAttributeUsesDefault(t *testing.T) {
 					},
 				}},
 			},
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId: test.RandomBytes(16),
 					SpanId:  test.RandomBytes(8),
@@ -808,12 +727,10 @@ func TestMissingServiceNameAttributeUsesDefault(t *testing.T) {
 
 	body := io.NopCloser(strings.NewReader(buf.String()))
 	ri := RequestInfo{
-		ApiKey:      "abc123DEF456ghi789jklm",
-		Dataset:     "legacy-dataset",
 		ContentType: "application/protobuf",
 	}
 
-	result, err := TranslateTraceRequestFromReader(body, ri)
+	result, err := TranslateTraceReqFromReader(body, ri)
 	assert.Nil(t, err)
 	batch := result.Batches[0]
 	assert.Equal(t, "unknown_service", batch.Dataset)
@@ -822,7 +739,7 @@ func TestMissingServiceNameAttributeUsesDefault(t *testing.T) {
 func TestMissingServiceNameResourceUsesDefault(t *testing.T) {
 	req := &collectortrace.ExportTraceServiceRequest{
 		ResourceSpans: []*trace.ResourceSpans{{
-			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
 				Spans: []*trace.Span{{
 					TraceId: test.RandomBytes(16),
 					SpanId:  test.RandomBytes(8),
@@ -840,12 +757,10 @@ func TestMissingServiceNameResourceUsesDefault(t *testing.T) {
 
 	body := io.NopCloser(strings.NewReader(buf.String()))
 	ri := RequestInfo{
-		ApiKey:      "abc123DEF456ghi789jklm",
-		Dataset:     "legacy-dataset",
 		ContentType: "application/protobuf",
 	}
 
-	result, err := TranslateTraceRequestFromReader(body, ri)
+	result, err := TranslateTraceReqFromReader(body, ri)
 	assert.Nil(t, err)
 	batch := result.Batches[0]
 	assert.Equal(t, "unknown_service", batch.Dataset)
@@ -900,7 +815,7 @@ func TestEmptyOrInvalidServiceNameAttributeUsesDefault(t *testing.T) {
 			req := &collectortrace.ExportTraceServiceRequest{
 				ResourceSpans: []*trace.ResourceSpans{{
 					Resource: tc.resource,
-					InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+					ScopeSpans: []*trace.ScopeSpans{{
 						Spans: []*trace.Span{{
 							TraceId: test.RandomBytes(16),
 							SpanId:  test.RandomBytes(8),
@@ -918,12 +833,10 @@ func TestEmptyOrInvalidServiceNameAttributeUsesDefault(t *testing.T) {
 
 			body := io.NopCloser(strings.NewReader(buf.String()))
 			ri := RequestInfo{
-				ApiKey:      "abc123DEF456ghi789jklm",
-				Dataset:     "legacy-dataset",
 				ContentType: "application/protobuf",
 			}
 
-			result, err := TranslateTraceRequestFromReader(body, ri)
+			result, err := TranslateTraceReqFromReader(body, ri)
 			assert.Nil(t, err)
 			batch := result.Batches[0]
 			assert.NotNil(t, batch.Dataset)
@@ -973,7 +886,7 @@ func TestUnknownServiceNameIsTruncatedForDatadset(t *testing.T) {
 							},
 						}},
 					},
-					InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{
+					ScopeSpans: []*trace.ScopeSpans{{
 						Spans: []*trace.Span{{
 							TraceId: test.RandomBytes(16),
 							SpanId:  test.RandomBytes(8),
@@ -991,12 +904,10 @@ func TestUnknownServiceNameIsTruncatedForDatadset(t *testing.T) {
 
 			body := io.NopCloser(strings.NewReader(buf.String()))
 			ri := RequestInfo{
-				ApiKey:      "abc123DEF456ghi789jklm",
-				Dataset:     "legacy-dataset",
 				ContentType: "application/protobuf",
 			}
 
-			result, err := TranslateTraceRequestFromReader(body, ri)
+			result, err := TranslateTraceReqFromReader(body, ri)
 			assert.Nil(t, err)
 
 			assert.Equal(t, 1, len(result.Batches))
@@ -1005,7 +916,7 @@ func TestUnknownServiceNameIsTruncatedForDatadset(t *testing.T) {
 
 			assert.Equal(t, 1, len(result.Batches[0].Events))
 			event := result.Batches[0].Events[0]
-			assert.Equal(t, tc.expectedEventServiceName, event.Attributes["service.name"])
+			assert.Equal(t, tc.expectedEventServiceName, event.Attributes["resourceAttributes"].(map[string]interface{})["service.name"])
 		})
 	}
 }
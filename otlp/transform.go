@@ -0,0 +1,273 @@
+package otlp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Transform operations
+const (
+	TransformRename = "rename"
+	TransformMove   = "move"
+	TransformDrop   = "drop"
+	TransformHash   = "hash"
+	TransformSet    = "set"
+	TransformCopy   = "copy"
+)
+
+// Attribute scopes a transform step can operate on
+const (
+	ScopeResource = "resource"
+	ScopeSpan     = "span"
+	ScopeEvent    = "event"
+)
+
+var scopeAttributeKeys = map[string]string{
+	ScopeResource: "resourceAttributes",
+	ScopeSpan:     "spanAttributes",
+	ScopeEvent:    "eventAttributes",
+}
+
+// TransformStep is one operation of an attribute transform chain
+// Scope selects the attribute map for rename, drop, hash and set, defaulting to span
+// From and To select the maps for move and copy, copy defaults to resource to span
+// NewKey renames the key, for move and copy it defaults to Key
+// The HMAC key for hash is Secret or the value of the SecretEnv environment variable
+type TransformStep struct {
+	Op        string      `json:"op" yaml:"op"`
+	Scope     string      `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Key       string      `json:"key,omitempty" yaml:"key,omitempty"`
+	NewKey    string      `json:"new_key,omitempty" yaml:"new_key,omitempty"`
+	From      string      `json:"from,omitempty" yaml:"from,omitempty"`
+	To        string      `json:"to,omitempty" yaml:"to,omitempty"`
+	Pattern   string      `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Value     interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+	Secret    string      `json:"secret,omitempty" yaml:"secret,omitempty"`
+	SecretEnv string      `json:"secret_env,omitempty" yaml:"secret_env,omitempty"`
+}
+
+// TransformConfig is the file format for a transform chain
+type TransformConfig struct {
+	Transforms []TransformStep `json:"transforms" yaml:"transforms"`
+}
+
+// TransformChain applies a validated list of transform steps to events in order
+type TransformChain struct {
+	steps []transformStep
+}
+
+type transformStep struct {
+	op      string
+	scope   string
+	from    string
+	to      string
+	key     string
+	newKey  string
+	pattern *regexp.Regexp
+	value   interface{}
+	secret  []byte
+}
+
+// NewTransformChain validates the steps, returning an error for unknown operations or missing fields
+func NewTransformChain(steps []TransformStep) (*TransformChain, error) {
+	chain := &TransformChain{}
+	for i, step := range steps {
+		compiled, err := compileTransformStep(step)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d (%s): %w", i, step.Op, err)
+		}
+		chain.steps = append(chain.steps, compiled)
+	}
+	return chain, nil
+}
+
+// ParseTransformConfig parses a YAML or JSON transform config, unknown fields are rejected
+func ParseTransformConfig(data []byte) (*TransformChain, error) {
+	var config TransformConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse transform config: %w", err)
+	}
+	return NewTransformChain(config.Transforms)
+}
+
+// LoadTransformConfig reads a YAML or JSON transform config from a file
+func LoadTransformConfig(path string) (*TransformChain, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTransformConfig(data)
+}
+
+func compileTransformStep(step TransformStep) (transformStep, error) {
+	compiled := transformStep{
+		op:     step.Op,
+		scope:  defaultString(step.Scope, ScopeSpan),
+		from:   step.From,
+		to:     step.To,
+		key:    step.Key,
+		newKey: defaultString(step.NewKey, step.Key),
+		value:  step.Value,
+	}
+	if err := validateScope(compiled.scope); err != nil {
+		return compiled, err
+	}
+
+	switch step.Op {
+	case TransformRename:
+		if step.Key == "" || step.NewKey == "" {
+			return compiled, fmt.Errorf("key and new_key are required")
+		}
+	case TransformMove, TransformCopy:
+		if step.Key == "" {
+			return compiled, fmt.Errorf("key is required")
+		}
+		if step.Op == TransformCopy {
+			compiled.from = defaultString(step.From, ScopeResource)
+			compiled.to = defaultString(step.To, ScopeSpan)
+		}
+		if err := validateScope(compiled.from); err != nil {
+			return compiled, err
+		}
+		if err := validateScope(compiled.to); err != nil {
+			return compiled, err
+		}
+		if compiled.from == compiled.to && compiled.key == compiled.newKey {
+			return compiled, fmt.Errorf("from and to are the same attribute")
+		}
+	case TransformDrop:
+		if step.Key == "" && step.Pattern == "" {
+			return compiled, fmt.Errorf("key or pattern is required")
+		}
+		if step.Pattern != "" {
+			pattern, err := regexp.Compile(step.Pattern)
+			if err != nil {
+				return compiled, err
+			}
+			compiled.pattern = pattern
+		}
+	case TransformHash:
+		if step.Key == "" {
+			return compiled, fmt.Errorf("key is required")
+		}
+		secret := step.Secret
+		if step.SecretEnv != "" {
+			secret = os.Getenv(step.SecretEnv)
+		}
+		if secret == "" {
+			return compiled, fmt.Errorf("secret or secret_env is required")
+		}
+		compiled.secret = []byte(secret)
+	case TransformSet:
+		if step.Key == "" || step.Value == nil {
+			return compiled, fmt.Errorf("key and value are required")
+		}
+	default:
+		return compiled, fmt.Errorf("unknown transform op %q", step.Op)
+	}
+	return compiled, nil
+}
+
+func validateScope(scope string) error {
+	if _, ok := scopeAttributeKeys[scope]; !ok {
+		return fmt.Errorf("unknown attribute scope %q", scope)
+	}
+	return nil
+}
+
+func defaultString(val, def string) string {
+	if val == "" {
+		return def
+	}
+	return val
+}
+
+// Apply runs the chain over the attributes of one event
+// Attribute maps can be shared between events, so a map is copied before it is first modified
+func (c *TransformChain) Apply(ev *Event) {
+	if c == nil || len(c.steps) == 0 {
+		return
+	}
+	t := eventTransform{attrs: ev.Attributes}
+	for _, step := range c.steps {
+		step.apply(&t)
+	}
+}
+
+type eventTransform struct {
+	attrs  map[string]interface{}
+	copied map[string]bool
+}
+
+// read returns the attribute map for scope, which may be nil
+func (t *eventTransform) read(scope string) map[string]interface{} {
+	m, _ := t.attrs[scopeAttributeKeys[scope]].(map[string]interface{})
+	return m
+}
+
+// write returns a copy of the attribute map for scope owned by this event
+func (t *eventTransform) write(scope string) map[string]interface{} {
+	if t.copied[scope] {
+		return t.read(scope)
+	}
+	existing := t.read(scope)
+	m := make(map[string]interface{}, len(existing))
+	for k, v := range existing {
+		m[k] = v
+	}
+	t.attrs[scopeAttributeKeys[scope]] = m
+	if t.copied == nil {
+		t.copied = map[string]bool{}
+	}
+	t.copied[scope] = true
+	return m
+}
+
+func (s transformStep) apply(t *eventTransform) {
+	switch s.op {
+	case TransformRename:
+		if val, ok := t.read(s.scope)[s.key]; ok {
+			m := t.write(s.scope)
+			delete(m, s.key)
+			m[s.newKey] = val
+		}
+	case TransformMove, TransformCopy:
+		if val, ok := t.read(s.from)[s.key]; ok {
+			if s.op == TransformMove {
+				delete(t.write(s.from), s.key)
+			}
+			t.write(s.to)[s.newKey] = val
+		}
+	case TransformDrop:
+		var drop []string
+		for k := range t.read(s.scope) {
+			if k == s.key || (s.pattern != nil && s.pattern.MatchString(k)) {
+				drop = append(drop, k)
+			}
+		}
+		if len(drop) > 0 {
+			m := t.write(s.scope)
+			for _, k := range drop {
+				delete(m, k)
+			}
+		}
+	case TransformHash:
+		if val, ok := t.read(s.scope)[s.key]; ok {
+			mac := hmac.New(sha256.New, s.secret)
+			mac.Write([]byte(fmt.Sprint(val)))
+			t.write(s.scope)[s.key] = hex.EncodeToString(mac.Sum(nil))
+		}
+	case TransformSet:
+		t.write(s.scope)[s.key] = s.value
+	}
+}
@@ -0,0 +1,131 @@
+package otlp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const transformYAML = `
+transforms:
+  - op: rename
+    key: http.method
+    new_key: http.request.method
+  - op: move
+    from: span
+    to: resource
+    key: deployment.environment
+  - op: drop
+    scope: span
+    pattern: "^internal\\."
+  - op: hash
+    key: user.email
+    secret: s3cret
+  - op: set
+    scope: resource
+    key: team
+    value: payments
+  - op: copy
+    key: service.name
+`
+
+func newTransformEvent() Event {
+	return Event{Attributes: map[string]interface{}{
+		"resourceAttributes": map[string]interface{}{"service.name": "checkout"},
+		"spanAttributes": map[string]interface{}{
+			"http.method":            "GET",
+			"deployment.environment": "prod",
+			"internal.debug":         true,
+			"internal.id":            int64(1),
+			"user.email":             "a@example.com",
+		},
+	}}
+}
+
+func TestTransformChain(t *testing.T) {
+	chain, err := ParseTransformConfig([]byte(transformYAML))
+	require.NoError(t, err)
+
+	resourceAttrs := map[string]interface{}{"service.name": "checkout"}
+	ev := newTransformEvent()
+	ev.Attributes["resourceAttributes"] = resourceAttrs
+	chain.Apply(&ev)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("a@example.com"))
+	assert.Equal(t, map[string]interface{}{
+		"service.name":           "checkout",
+		"deployment.environment": "prod",
+		"team":                   "payments",
+	}, ev.Attributes["resourceAttributes"])
+	assert.Equal(t, map[string]interface{}{
+		"http.request.method": "GET",
+		"user.email":          hex.EncodeToString(mac.Sum(nil)),
+		"service.name":        "checkout",
+	}, ev.Attributes["spanAttributes"])
+	// shared resource maps are copied rather than modified
+	assert.Equal(t, map[string]interface{}{"service.name": "checkout"}, resourceAttrs)
+}
+
+func TestParseTransformConfigJSON(t *testing.T) {
+	chain, err := ParseTransformConfig([]byte(`{"transforms": [{"op": "set", "scope": "event", "key": "sampled", "value": true}]}`))
+	require.NoError(t, err)
+	ev := Event{Attributes: map[string]interface{}{}}
+	chain.Apply(&ev)
+	assert.Equal(t, map[string]interface{}{"sampled": true}, ev.Attributes["eventAttributes"])
+}
+
+func TestParseTransformConfigErrors(t *testing.T) {
+	for name, config := range map[string]string{
+		"unknown op":      `{"transforms": [{"op": "uppercase", "key": "a"}]}`,
+		"unknown field":   `{"transforms": [{"op": "set", "key": "a", "value": 1, "colour": "red"}]}`,
+		"unknown scope":   `{"transforms": [{"op": "set", "scope": "link", "key": "a", "value": 1}]}`,
+		"missing new key": `{"transforms": [{"op": "rename", "key": "a"}]}`,
+		"bad pattern":     `{"transforms": [{"op": "drop", "pattern": "("}]}`,
+		"missing secret":  `{"transforms": [{"op": "hash", "key": "a", "secret_env": "HUSKY_TEST_UNSET_SECRET"}]}`,
+		"same attribute":  `{"transforms": [{"op": "move", "from": "span", "to": "span", "key": "a"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTransformConfig([]byte(config))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadTransformConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transforms.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(transformYAML), 0600))
+	chain, err := LoadTransformConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 6, len(chain.steps))
+
+	_, err = LoadTransformConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestTranslateTraceReqWithTransforms(t *testing.T) {
+	chain, err := NewTransformChain([]TransformStep{{Op: TransformSet, Scope: ScopeResource, Key: "team", Value: "payments"}})
+	require.NoError(t, err)
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{
+			Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "checkout")}},
+			ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{newTestSpan("a"), newTestSpan("b")}}},
+		}},
+	}
+
+	result, err := TranslateTraceReq(req, RequestInfo{Dataset: "dataset"}, WithTransforms(chain))
+	require.NoError(t, err)
+	for _, ev := range result.Batches[0].Events {
+		assert.Equal(t, map[string]interface{}{"service.name": "checkout", "team": "payments"}, ev.Attributes["resourceAttributes"])
+	}
+}
@@ -0,0 +1,51 @@
+package otlp
+
+import (
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// InvalidSpanPolicy decides what the trace translator does with spans that fail validation
+type InvalidSpanPolicy int
+
+const (
+	// InvalidSpanKeep translates invalid spans as they are, with the reason set in spanInvalidReason
+	InvalidSpanKeep InvalidSpanPolicy = iota
+	// InvalidSpanDrop drops invalid spans and counts them as rejected
+	InvalidSpanDrop
+	// InvalidSpanFix repairs invalid spans where possible, spans with an end time before their start
+	// time end when they start. Spans with invalid IDs cannot be repaired so they are dropped
+	InvalidSpanFix
+)
+
+// Reasons a span fails validation
+const (
+	InvalidReasonTraceID        = "invalid_trace_id"
+	InvalidReasonSpanID         = "invalid_span_id"
+	InvalidReasonEndBeforeStart = "end_before_start"
+)
+
+const spanIDLength = 8
+
+// validateSpan returns the reason the span is invalid, or an empty string if it is valid
+// Trace IDs must be 8 or 16 bytes and span IDs 8 bytes, neither can be all zeroes
+func validateSpan(span *trace.Span) string {
+	if len(span.TraceId) != traceIDShortLength && len(span.TraceId) != traceIDLongLength || isZeroID(span.TraceId) {
+		return InvalidReasonTraceID
+	}
+	if len(span.SpanId) != spanIDLength || isZeroID(span.SpanId) {
+		return InvalidReasonSpanID
+	}
+	if span.EndTimeUnixNano < span.StartTimeUnixNano {
+		return InvalidReasonEndBeforeStart
+	}
+	return ""
+}
+
+func isZeroID(id []byte) bool {
+	for _, b := range id {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestValidateSpan(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(span *trace.Span)
+		reason string
+	}{
+		{name: "valid", modify: func(span *trace.Span) {}},
+		{name: "short trace ID", modify: func(span *trace.Span) { span.TraceId = span.TraceId[:8] }},
+		{name: "empty trace ID", modify: func(span *trace.Span) { span.TraceId = nil }, reason: InvalidReasonTraceID},
+		{name: "odd trace ID", modify: func(span *trace.Span) { span.TraceId = span.TraceId[:12] }, reason: InvalidReasonTraceID},
+		{name: "zero trace ID", modify: func(span *trace.Span) { span.TraceId = make([]byte, 16) }, reason: InvalidReasonTraceID},
+		{name: "empty span ID", modify: func(span *trace.Span) { span.SpanId = nil }, reason: InvalidReasonSpanID},
+		{name: "long span ID", modify: func(span *trace.Span) { span.SpanId = make([]byte, 16) }, reason: InvalidReasonSpanID},
+		{name: "zero span ID", modify: func(span *trace.Span) { span.SpanId = make([]byte, 8) }, reason: InvalidReasonSpanID},
+		{name: "end before start", modify: func(span *trace.Span) { span.EndTimeUnixNano = span.StartTimeUnixNano - 1 }, reason: InvalidReasonEndBeforeStart},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := newTestSpan("span")
+			tt.modify(span)
+			assert.Equal(t, tt.reason, validateSpan(span))
+		})
+	}
+}
+
+func newInvalidSpansRequest() *collectortrace.ExportTraceServiceRequest {
+	backwards := newTestSpan("backwards")
+	backwards.EndTimeUnixNano = backwards.StartTimeUnixNano - 2000000
+	noSpanID := newTestSpan("no_span_id")
+	noSpanID.SpanId = nil
+	noSpanID.Events = []*trace.Span_Event{{Name: "event"}}
+	return newTestRequest(newTestSpan("valid"), backwards, noSpanID)
+}
+
+func TestTranslateTraceReqInvalidSpanPolicy(t *testing.T) {
+	t.Run("keep", func(t *testing.T) {
+		result, err := TranslateTraceReq(newInvalidSpansRequest(), RequestInfo{Dataset: "dataset"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.RejectedSpans)
+		events := result.Batches[0].Events
+		require.Equal(t, 3, len(events))
+		assert.NotContains(t, events[0].Attributes, "spanInvalidReason")
+		assert.Equal(t, InvalidReasonEndBeforeStart, events[1].Attributes["spanInvalidReason"])
+		assert.Equal(t, -2.0, events[1].Attributes["durationMs"])
+		assert.Equal(t, InvalidReasonSpanID, events[2].Attributes["spanInvalidReason"])
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		result, err := TranslateTraceReq(newInvalidSpansRequest(), RequestInfo{Dataset: "dataset"}, WithInvalidSpanPolicy(InvalidSpanDrop))
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.RejectedSpans)
+		events := result.Batches[0].Events
+		require.Equal(t, 1, len(events))
+		assert.Equal(t, "valid", events[0].Attributes["spanName"])
+	})
+
+	t.Run("fix", func(t *testing.T) {
+		request := newInvalidSpansRequest()
+		backwards := request.ResourceSpans[0].ScopeSpans[0].Spans[1]
+		result, err := TranslateTraceReq(request, RequestInfo{Dataset: "dataset"}, WithInvalidSpanPolicy(InvalidSpanFix))
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.RejectedSpans)
+		events := result.Batches[0].Events
+		require.Equal(t, 2, len(events))
+		fixed := events[1].Attributes
+		assert.Equal(t, "backwards", fixed["spanName"])
+		assert.Equal(t, 0.0, fixed["durationMs"])
+		assert.Equal(t, fixed["startTime"], fixed["endTime"])
+		assert.NotContains(t, fixed, "spanInvalidReason")
+		// the request is not modified
+		assert.True(t, backwards.EndTimeUnixNano < backwards.StartTimeUnixNano)
+	})
+}
+
+func TestTranslateTraceReqCountsSpansAfterValidation(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("")
+	result, err := TranslateTraceReq(newInvalidSpansRequest(), RequestInfo{Dataset: "dataset"},
+		WithInvalidSpanPolicy(InvalidSpanFix), WithMetricsRecorder(recorder))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.RejectedSpans)
+
+	output := scrapeMetrics(recorder)
+	assert.Contains(t, output, `husky_translate_items_total{signal="traces",kind="span"} 2`)
+	assert.Contains(t, output, `husky_translate_items_total{signal="traces",kind="span_event"} 0`)
+}
@@ -0,0 +1,372 @@
+// Package xray translates AWS X-Ray segment documents into the same structure produced by the otlp package
+package xray
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/honeycombio/husky/otlp"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// daemonHeader prefixes documents sent to the X-Ray daemon over UDP
+	daemonHeader = `{"format":"json","version":1}`
+)
+
+var (
+	errInvalidTraceID = errors.New("invalid X-Ray trace ID")
+	errInvalidSpanID  = errors.New("invalid X-Ray segment ID")
+)
+
+// putTraceSegmentsRequest is the body of the X-Ray PutTraceSegments API
+type putTraceSegmentsRequest struct {
+	TraceSegmentDocuments []string
+}
+
+// segment is a segment or subsegment document
+// Subsegments nested in a segment inherit its trace ID and use its ID as their parent
+type segment struct {
+	Name        string                 `json:"name"`
+	ID          string                 `json:"id"`
+	TraceID     string                 `json:"trace_id"`
+	ParentID    string                 `json:"parent_id"`
+	Type        string                 `json:"type"`
+	Namespace   string                 `json:"namespace"`
+	Origin      string                 `json:"origin"`
+	StartTime   float64                `json:"start_time"`
+	EndTime     float64                `json:"end_time"`
+	InProgress  bool                   `json:"in_progress"`
+	Fault       bool                   `json:"fault"`
+	Error       bool                   `json:"error"`
+	Throttle    bool                   `json:"throttle"`
+	Cause       json.RawMessage        `json:"cause"`
+	HTTP        map[string]interface{} `json:"http"`
+	AWS         map[string]interface{} `json:"aws"`
+	SQL         map[string]interface{} `json:"sql"`
+	Annotations map[string]interface{} `json:"annotations"`
+	Subsegments []*segment             `json:"subsegments"`
+}
+
+// cause describes the exceptions recorded on a segment
+// It is sent as a string instead when it refers to an exception in a subsegment
+type cause struct {
+	Exceptions []exception `json:"exceptions"`
+}
+
+type exception struct {
+	Message string       `json:"message"`
+	Type    string       `json:"type"`
+	Remote  bool         `json:"remote"`
+	Stack   []stackFrame `json:"stack"`
+}
+
+type stackFrame struct {
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Label string `json:"label"`
+}
+
+// TranslateTraceReqFromReader translates the body of an X-Ray PutTraceSegments request into Opsramp-friendly structure
+func TranslateTraceReqFromReader(body io.ReadCloser, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	data, err := otlp.ReadBody(body, ri.ContentEncoding)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	var request putTraceSegmentsRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return TranslateSegmentDocuments(request.TraceSegmentDocuments, ri, opts...)
+}
+
+// TranslateSegmentDocuments translates segment and independent subsegment documents
+// Documents may start with the header line used by the X-Ray daemon
+func TranslateSegmentDocuments(documents []string, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	request, err := newTraceRequest(documents)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return otlp.TranslateTraceReq(request, ri, opts...)
+}
+
+// newTraceRequest converts the documents into an OTLP request with a resource per segment name and
+// origin, in the order they were first seen. Independent subsegments only name the call they record,
+// so they have no service.name
+func newTraceRequest(documents []string) (*collectorTrace.ExportTraceServiceRequest, error) {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	librarySpans := make(map[[2]string]*trace.ScopeSpans)
+	for _, document := range documents {
+		seg, err := parseSegment(document)
+		if err != nil {
+			return nil, err
+		}
+		traceID, err := convertTraceID(seg.TraceID)
+		if err != nil {
+			return nil, err
+		}
+
+		var serviceName string
+		if seg.Type != "subsegment" {
+			serviceName = seg.Name
+		}
+		key := [2]string{serviceName, seg.Origin}
+		library, ok := librarySpans[key]
+		if !ok {
+			res := &resource.Resource{}
+			if serviceName != "" {
+				res.Attributes = append(res.Attributes, otlp.NewAttribute("service.name", serviceName))
+			}
+			if seg.Origin != "" {
+				res.Attributes = append(res.Attributes, otlp.NewAttribute("xray.origin", seg.Origin))
+			}
+			library = &trace.ScopeSpans{}
+			librarySpans[key] = library
+			request.ResourceSpans = append(request.ResourceSpans, &trace.ResourceSpans{
+				Resource:   res,
+				ScopeSpans: []*trace.ScopeSpans{library},
+			})
+		}
+		if library.Spans, err = appendSegmentSpans(library.Spans, seg, traceID, seg.ParentID, seg.Type == "subsegment"); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+func parseSegment(document string) (*segment, error) {
+	data := []byte(strings.TrimSpace(document))
+	if bytes.HasPrefix(data, []byte(daemonHeader)) {
+		data = bytes.TrimSpace(data[len(daemonHeader):])
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var seg segment
+	if err := decoder.Decode(&seg); err != nil {
+		return nil, err
+	}
+	if seg.ID == "" {
+		return nil, errors.New("segment has no id")
+	}
+	return &seg, nil
+}
+
+// convertTraceID converts an X-Ray trace ID, 1-{8 hex digit epoch}-{24 hex digit identifier},
+// into the 16 bytes of an OTLP trace ID
+func convertTraceID(traceID string) ([]byte, error) {
+	parts := strings.Split(traceID, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return nil, errInvalidTraceID
+	}
+	id, err := hex.DecodeString(parts[1] + parts[2])
+	if err != nil {
+		return nil, errInvalidTraceID
+	}
+	return id, nil
+}
+
+// convertSpanID converts a 16 hex digit segment ID into the 8 bytes of an OTLP span ID
+func convertSpanID(spanID string) ([]byte, error) {
+	id, err := hex.DecodeString(spanID)
+	if err != nil || len(id) != 8 {
+		return nil, errInvalidSpanID
+	}
+	return id, nil
+}
+
+// appendSegmentSpans appends the span for seg and, depth first, its subsegments
+// The exceptions in its cause become span events
+func appendSegmentSpans(spans []*trace.Span, seg *segment, traceID []byte, parentID string, subsegment bool) ([]*trace.Span, error) {
+	spanID, err := convertSpanID(seg.ID)
+	if err != nil {
+		return nil, err
+	}
+	startTime := secondsToTime(seg.StartTime)
+	endTime := startTime
+	if !seg.InProgress && seg.EndTime > 0 {
+		endTime = secondsToTime(seg.EndTime)
+	}
+
+	spanAttrs := make(map[string]interface{})
+	addHTTPAttributes(spanAttrs, seg.HTTP)
+	flattenAttributes(spanAttrs, "aws", seg.AWS)
+	flattenAttributes(spanAttrs, "sql", seg.SQL)
+	for k, v := range seg.Annotations {
+		spanAttrs[k] = convertValue(v)
+	}
+	if seg.Namespace != "" {
+		spanAttrs["xray.namespace"] = seg.Namespace
+	}
+	if seg.InProgress {
+		spanAttrs["xray.in_progress"] = true
+	}
+	if seg.Fault {
+		spanAttrs["xray.fault"] = true
+	}
+	if seg.Error {
+		spanAttrs["xray.error"] = true
+	}
+	if seg.Throttle {
+		spanAttrs["xray.throttle"] = true
+	}
+	attrs := make([]*common.KeyValue, 0, len(spanAttrs))
+	for k, v := range spanAttrs {
+		attrs = append(attrs, otlp.NewAttribute(k, v))
+	}
+
+	span := &trace.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		Name:              seg.Name,
+		Kind:              getSpanKind(seg, subsegment),
+		StartTimeUnixNano: uint64(startTime.UnixNano()),
+		EndTimeUnixNano:   uint64(endTime.UnixNano()),
+		Attributes:        attrs,
+	}
+	if parentID != "" {
+		if span.ParentSpanId, err = convertSpanID(parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	exceptions := parseExceptions(seg.Cause)
+	if seg.Fault || seg.Error || seg.Throttle {
+		span.Status = &trace.Status{Code: trace.Status_STATUS_CODE_ERROR}
+		if len(exceptions) > 0 {
+			span.Status.Message = exceptions[0].Message
+		}
+	}
+	for _, e := range exceptions {
+		span.Events = append(span.Events, &trace.Span_Event{
+			TimeUnixNano: uint64(endTime.UnixNano()),
+			Name:         "exception",
+			Attributes:   exceptionAttributes(e),
+		})
+	}
+
+	spans = append(spans, span)
+	for _, sub := range seg.Subsegments {
+		if sub == nil {
+			continue
+		}
+		if spans, err = appendSegmentSpans(spans, sub, traceID, seg.ID, true); err != nil {
+			return nil, err
+		}
+	}
+	return spans, nil
+}
+
+// getSpanKind returns server for segments, client for subsegments calling AWS or remote services
+// and internal for other subsegments
+func getSpanKind(seg *segment, subsegment bool) trace.Span_SpanKind {
+	switch {
+	case !subsegment:
+		return trace.Span_SPAN_KIND_SERVER
+	case seg.Namespace == "aws" || seg.Namespace == "remote":
+		return trace.Span_SPAN_KIND_CLIENT
+	default:
+		return trace.Span_SPAN_KIND_INTERNAL
+	}
+}
+
+// addHTTPAttributes maps the http block to the OpenTelemetry HTTP attribute names
+// Fields without an OpenTelemetry equivalent keep their X-Ray path under http.
+func addHTTPAttributes(attrs map[string]interface{}, block map[string]interface{}) {
+	names := map[string]string{
+		"request.method":          "http.method",
+		"request.url":             "http.url",
+		"request.user_agent":      "http.user_agent",
+		"request.client_ip":       "http.client_ip",
+		"response.status":         "http.status_code",
+		"response.content_length": "http.response_content_length",
+	}
+	flat := make(map[string]interface{})
+	flattenAttributes(flat, "", block)
+	for k, v := range flat {
+		if name, ok := names[k]; ok {
+			attrs[name] = v
+		} else {
+			attrs["http."+k] = v
+		}
+	}
+}
+
+// flattenAttributes adds the values of a nested block as dotted keys under prefix
+func flattenAttributes(attrs map[string]interface{}, prefix string, block map[string]interface{}) {
+	for k, v := range block {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenAttributes(attrs, key, nested)
+			continue
+		}
+		if value := convertValue(v); value != nil {
+			attrs[key] = value
+		}
+	}
+}
+
+// convertValue converts decoded JSON numbers into attribute values, keeping integers as int64
+// Arrays and objects are stored as JSON strings by otlp.NewAttribute
+func convertValue(v interface{}) interface{} {
+	if number, ok := v.(json.Number); ok {
+		if i, err := strconv.ParseInt(number.String(), 10, 64); err == nil {
+			return i
+		}
+		f, _ := number.Float64()
+		return f
+	}
+	return v
+}
+
+func parseExceptions(raw json.RawMessage) []exception {
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil
+	}
+	var c cause
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil
+	}
+	return c.Exceptions
+}
+
+func exceptionAttributes(e exception) []*common.KeyValue {
+	var attrs []*common.KeyValue
+	if e.Type != "" {
+		attrs = append(attrs, otlp.NewAttribute("exception.type", e.Type))
+	}
+	if e.Message != "" {
+		attrs = append(attrs, otlp.NewAttribute("exception.message", e.Message))
+	}
+	if len(e.Stack) > 0 {
+		lines := make([]string, 0, len(e.Stack))
+		for _, frame := range e.Stack {
+			lines = append(lines, fmt.Sprintf("%s (%s:%d)", frame.Label, frame.Path, frame.Line))
+		}
+		attrs = append(attrs, otlp.NewAttribute("exception.stacktrace", strings.Join(lines, "\n")))
+	}
+	if e.Remote {
+		attrs = append(attrs, otlp.NewAttribute("xray.exception.remote", true))
+	}
+	return attrs
+}
+
+// secondsToTime converts X-Ray's fractional epoch seconds, rounding to the microsecond
+// precision X-Ray records to avoid floating point noise
+func secondsToTime(seconds float64) time.Time {
+	micros := int64(math.Round(seconds * 1e6))
+	return time.Unix(0, micros*int64(time.Microsecond))
+}
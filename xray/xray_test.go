@@ -0,0 +1,164 @@
+package xray
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const testSegment = `{
+  "name": "checkout",
+  "id": "70de5b6f19ff9a0a",
+  "trace_id": "1-581cf771-a006649127e371903a2de979",
+  "start_time": 1478293361.271,
+  "end_time": 1478293361.449,
+  "origin": "AWS::Lambda::Function",
+  "fault": true,
+  "http": {
+    "request": {"method": "POST", "url": "https://example.com/cart", "x_forwarded_for": true},
+    "response": {"status": 502, "content_length": 120}
+  },
+  "annotations": {"customer": "c-123", "items": 3},
+  "cause": {
+    "exceptions": [{"message": "upstream unavailable", "type": "HTTPError", "stack": [{"path": "cart.py", "line": 12, "label": "checkout"}]}]
+  },
+  "subsegments": [
+    {
+      "name": "DynamoDB",
+      "id": "53995c3f42cd8ad8",
+      "namespace": "aws",
+      "start_time": 1478293361.3,
+      "end_time": 1478293361.35,
+      "throttle": true,
+      "error": true,
+      "aws": {"operation": "GetItem", "table_name": "carts", "retries": 1},
+      "subsegments": [
+        {"name": "marshal", "id": "0102030405060708", "start_time": 1478293361.31, "end_time": 1478293361.32}
+      ]
+    },
+    {
+      "name": "orders-db",
+      "id": "1112131415161718",
+      "namespace": "remote",
+      "start_time": 1478293361.36,
+      "in_progress": true,
+      "sql": {"url": "jdbc:postgresql://db:5432/orders", "database_type": "PostgreSQL", "sanitized_query": "SELECT * FROM orders WHERE id = ?"}
+    }
+  ]
+}`
+
+func TestTranslateSegmentDocuments(t *testing.T) {
+	result, err := TranslateSegmentDocuments([]string{testSegment}, otlp.RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "dataset", result.Batches[0].Dataset)
+
+	events := result.Batches[0].Events
+	require.Equal(t, 4, len(events))
+
+	root := events[0]
+	assert.Equal(t, time.Unix(1478293361, 271000000).UTC(), root.Timestamp)
+	assert.Equal(t, "581cf771a006649127e371903a2de979", root.Attributes["traceTraceID"])
+	assert.Equal(t, "70de5b6f19ff9a0a", root.Attributes["traceSpanID"])
+	assert.Equal(t, "server", root.Attributes["spanKind"])
+	assert.Equal(t, 178.0, root.Attributes["durationMs"])
+	assert.Equal(t, trace.Status_STATUS_CODE_ERROR, root.Attributes["statusCode"])
+	assert.Equal(t, true, root.Attributes["error"])
+	assert.Equal(t, "upstream unavailable", root.Attributes["statusMessage"])
+	assert.Equal(t, 1, root.Attributes["spanNumEvents"])
+	assert.Equal(t, map[string]interface{}{
+		"exception.type":       "HTTPError",
+		"exception.message":    "upstream unavailable",
+		"exception.stacktrace": "checkout (cart.py:12)",
+	}, root.Attributes["eventAttributes"])
+	assert.Equal(t, map[string]interface{}{"service.name": "checkout", "xray.origin": "AWS::Lambda::Function"}, root.Attributes["resourceAttributes"])
+	assert.Equal(t, map[string]interface{}{
+		"http.method":                  "POST",
+		"http.url":                     "https://example.com/cart",
+		"http.request.x_forwarded_for": true,
+		"http.status_code":             int64(502),
+		"http.response_content_length": int64(120),
+		"customer":                     "c-123",
+		"items":                        int64(3),
+		"xray.fault":                   true,
+	}, root.Attributes["spanAttributes"])
+	_, ok := root.Attributes["traceParentID"]
+	assert.False(t, ok)
+
+	dynamo := events[1]
+	assert.Equal(t, "581cf771a006649127e371903a2de979", dynamo.Attributes["traceTraceID"])
+	assert.Equal(t, "70de5b6f19ff9a0a", dynamo.Attributes["traceParentID"])
+	assert.Equal(t, "client", dynamo.Attributes["spanKind"])
+	assert.Equal(t, true, dynamo.Attributes["error"])
+	dynamoAttrs := dynamo.Attributes["spanAttributes"].(map[string]interface{})
+	assert.Equal(t, "GetItem", dynamoAttrs["aws.operation"])
+	assert.Equal(t, int64(1), dynamoAttrs["aws.retries"])
+	assert.Equal(t, true, dynamoAttrs["xray.throttle"])
+	_, ok = dynamo.Attributes["statusMessage"]
+	assert.False(t, ok)
+
+	marshal := events[2]
+	assert.Equal(t, "53995c3f42cd8ad8", marshal.Attributes["traceParentID"])
+	assert.Equal(t, "internal", marshal.Attributes["spanKind"])
+	assert.Equal(t, trace.Status_STATUS_CODE_UNSET, marshal.Attributes["statusCode"])
+	assert.Equal(t, false, marshal.Attributes["error"])
+	assert.Equal(t, map[string]interface{}{"service.name": "checkout", "xray.origin": "AWS::Lambda::Function"}, marshal.Attributes["resourceAttributes"])
+
+	orders := events[3]
+	assert.Equal(t, "client", orders.Attributes["spanKind"])
+	assert.Equal(t, 0.0, orders.Attributes["durationMs"])
+	ordersAttrs := orders.Attributes["spanAttributes"].(map[string]interface{})
+	assert.Equal(t, "PostgreSQL", ordersAttrs["sql.database_type"])
+	assert.Equal(t, true, ordersAttrs["xray.in_progress"])
+}
+
+func TestTranslateSegmentDocumentsWithoutRequestDataset(t *testing.T) {
+	result, err := TranslateSegmentDocuments([]string{testSegment}, otlp.RequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "checkout", result.Batches[0].Dataset)
+}
+
+func TestTranslateSegmentDocumentsIndependentSubsegment(t *testing.T) {
+	document := daemonHeader + "\n" + `{"type": "subsegment", "name": "S3", "id": "2122232425262728", "trace_id": "1-581cf771-a006649127e371903a2de979", "parent_id": "70de5b6f19ff9a0a", "namespace": "aws", "start_time": 1478293361.5, "end_time": 1478293361.6}`
+
+	result, err := TranslateSegmentDocuments([]string{document}, otlp.RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	assert.Equal(t, "dataset", result.Batches[0].Dataset)
+	ev := result.Batches[0].Events[0]
+	assert.Equal(t, map[string]interface{}{}, ev.Attributes["resourceAttributes"])
+	assert.Equal(t, "70de5b6f19ff9a0a", ev.Attributes["traceParentID"])
+	assert.Equal(t, "client", ev.Attributes["spanKind"])
+}
+
+func TestTranslateSegmentDocumentsInvalid(t *testing.T) {
+	for _, document := range []string{
+		`not json`,
+		`{"name": "a", "trace_id": "1-581cf771-a006649127e371903a2de979"}`,
+		`{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "581cf771a006649127e371903a2de979"}`,
+		`{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "1-581cf771-zz06649127e371903a2de979"}`,
+		`{"name": "a", "id": "70de5b6f", "trace_id": "1-581cf771-a006649127e371903a2de979"}`,
+	} {
+		_, err := TranslateSegmentDocuments([]string{document}, otlp.RequestInfo{})
+		assert.Equal(t, otlp.ErrFailedParseBody, err, document)
+	}
+}
+
+func TestTranslateTraceReqFromReader(t *testing.T) {
+	body, err := json.Marshal(map[string]interface{}{"TraceSegmentDocuments": []string{testSegment}})
+	require.NoError(t, err)
+
+	result, err := TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(body)), otlp.RequestInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, len(result.Batches[0].Events))
+
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader([]byte("["))), otlp.RequestInfo{})
+	assert.Equal(t, otlp.ErrFailedParseBody, err)
+}
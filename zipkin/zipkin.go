@@ -0,0 +1,249 @@
+// Package zipkin translates Zipkin v2 spans into the same structure produced by the otlp package
+package zipkin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+
+	"github.com/honeycombio/husky/otlp"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// TranslateTraceReqFromReader translates a Zipkin v2 HTTP request body into Opsramp-friendly structure
+// The body is decoded as JSON or as a zipkin.proto3 ListOfSpans depending on RequestInfo.ContentType
+func TranslateTraceReqFromReader(body io.ReadCloser, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	var translate func([]byte, otlp.RequestInfo, ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error)
+	switch ri.ContentType {
+	case "application/json":
+		translate = TranslateJSONSpans
+	case "application/x-protobuf", "application/protobuf":
+		translate = TranslateProtoSpans
+	default:
+		return nil, otlp.ErrInvalidContentType
+	}
+
+	data, err := otlp.ReadBody(body, ri.ContentEncoding)
+	if err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return translate(data, ri, opts...)
+}
+
+// TranslateJSONSpans translates a Zipkin v2 JSON array of spans
+func TranslateJSONSpans(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	var spans []*model.SpanModel
+	if err := json.Unmarshal(data, &spans); err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+	return otlp.TranslateTraceReq(newTraceRequest(spans), ri, opts...)
+}
+
+// TranslateProtoSpans translates a serialised zipkin.proto3 ListOfSpans
+// Unlike zipkin_proto3.ParseSpans, 64-bit trace IDs are accepted
+func TranslateProtoSpans(data []byte, ri otlp.RequestInfo, opts ...otlp.TranslateOption) (*otlp.TranslateTraceRequestResult, error) {
+	var list zipkin_proto3.ListOfSpans
+	if err := proto.Unmarshal(data, &list); err != nil {
+		return nil, otlp.ErrFailedParseBody
+	}
+
+	spans := make([]*model.SpanModel, 0, len(list.Spans))
+	for _, protoSpan := range list.Spans {
+		span, ok := protoSpanToModel(protoSpan)
+		if !ok {
+			return nil, otlp.ErrFailedParseBody
+		}
+		spans = append(spans, span)
+	}
+	return otlp.TranslateTraceReq(newTraceRequest(spans), ri, opts...)
+}
+
+// newTraceRequest converts the spans into an OTLP request with a resource per local service name,
+// in the order the services were first seen. Spans without one have no service.name
+func newTraceRequest(spans []*model.SpanModel) *collectorTrace.ExportTraceServiceRequest {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	librarySpans := make(map[string]*trace.ScopeSpans)
+	for _, span := range spans {
+		if span == nil {
+			continue
+		}
+		var serviceName string
+		if span.LocalEndpoint != nil {
+			serviceName = span.LocalEndpoint.ServiceName
+		}
+		library, ok := librarySpans[serviceName]
+		if !ok {
+			res := &resource.Resource{}
+			if serviceName != "" {
+				res.Attributes = []*common.KeyValue{otlp.NewAttribute("service.name", serviceName)}
+			}
+			library = &trace.ScopeSpans{}
+			librarySpans[serviceName] = library
+			request.ResourceSpans = append(request.ResourceSpans, &trace.ResourceSpans{
+				Resource:   res,
+				ScopeSpans: []*trace.ScopeSpans{library},
+			})
+		}
+		library.Spans = append(library.Spans, translateSpan(span))
+	}
+	return request
+}
+
+// translateSpan converts the span into an OTLP span, annotations become span events
+func translateSpan(span *model.SpanModel) *trace.Span {
+	var startTime uint64
+	if !span.Timestamp.IsZero() {
+		// spans without a timestamp are treated like OTLP spans with a zero start time
+		startTime = uint64(span.Timestamp.UnixNano())
+	}
+
+	attrs := make([]*common.KeyValue, 0, len(span.Tags)+6)
+	for k, v := range span.Tags {
+		attrs = append(attrs, otlp.NewAttribute(k, v))
+	}
+	attrs = appendEndpointAttributes(attrs, span.LocalEndpoint, "net.host.ip", "net.host.port")
+	attrs = appendEndpointAttributes(attrs, span.RemoteEndpoint, "net.peer.ip", "net.peer.port")
+	if span.RemoteEndpoint != nil && span.RemoteEndpoint.ServiceName != "" {
+		attrs = append(attrs, otlp.NewAttribute("peer.service", span.RemoteEndpoint.ServiceName))
+	}
+	if span.Shared {
+		attrs = append(attrs, otlp.NewAttribute("zipkin.shared", true))
+	}
+
+	otlpSpan := &trace.Span{
+		TraceId:           otlp.TraceIDBytes(span.TraceID.High, span.TraceID.Low),
+		SpanId:            otlp.SpanIDBytes(uint64(span.ID)),
+		Name:              span.Name,
+		Kind:              getSpanKind(span.Kind),
+		StartTimeUnixNano: startTime,
+		EndTimeUnixNano:   startTime + uint64(span.Duration),
+		Attributes:        attrs,
+		Status:            otlp.SpanStatusFromAttributes(attrs),
+	}
+	if span.ParentID != nil {
+		otlpSpan.ParentSpanId = otlp.SpanIDBytes(uint64(*span.ParentID))
+	}
+	for _, annotation := range span.Annotations {
+		otlpSpan.Events = append(otlpSpan.Events, &trace.Span_Event{
+			TimeUnixNano: uint64(annotation.Timestamp.UnixNano()),
+			Name:         annotation.Value,
+		})
+	}
+	return otlpSpan
+}
+
+func getSpanKind(kind model.Kind) trace.Span_SpanKind {
+	switch kind {
+	case model.Client:
+		return trace.Span_SPAN_KIND_CLIENT
+	case model.Server:
+		return trace.Span_SPAN_KIND_SERVER
+	case model.Producer:
+		return trace.Span_SPAN_KIND_PRODUCER
+	case model.Consumer:
+		return trace.Span_SPAN_KIND_CONSUMER
+	default:
+		return trace.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func appendEndpointAttributes(attrs []*common.KeyValue, endpoint *model.Endpoint, ipKey string, portKey string) []*common.KeyValue {
+	if endpoint == nil {
+		return attrs
+	}
+	if endpoint.IPv4 != nil {
+		attrs = append(attrs, otlp.NewAttribute(ipKey, endpoint.IPv4.String()))
+	} else if endpoint.IPv6 != nil {
+		attrs = append(attrs, otlp.NewAttribute(ipKey, endpoint.IPv6.String()))
+	}
+	if endpoint.Port != 0 {
+		attrs = append(attrs, otlp.NewAttribute(portKey, int64(endpoint.Port)))
+	}
+	return attrs
+}
+
+func protoSpanToModel(s *zipkin_proto3.Span) (*model.SpanModel, bool) {
+	if s == nil || len(s.Id) != 8 {
+		return nil, false
+	}
+	span := &model.SpanModel{
+		Name:           s.Name,
+		Kind:           protoKindToModel(s.Kind),
+		Timestamp:      time.Unix(0, int64(s.Timestamp)*int64(time.Microsecond)),
+		Duration:       time.Duration(s.Duration) * time.Microsecond,
+		Shared:         s.Shared,
+		LocalEndpoint:  protoEndpointToModel(s.LocalEndpoint),
+		RemoteEndpoint: protoEndpointToModel(s.RemoteEndpoint),
+		Tags:           s.Tags,
+	}
+	span.ID = model.ID(binary.BigEndian.Uint64(s.Id))
+
+	switch len(s.TraceId) {
+	case 16:
+		span.TraceID.High = binary.BigEndian.Uint64(s.TraceId[:8])
+		span.TraceID.Low = binary.BigEndian.Uint64(s.TraceId[8:])
+	case 8:
+		span.TraceID.Low = binary.BigEndian.Uint64(s.TraceId)
+	default:
+		return nil, false
+	}
+
+	switch len(s.ParentId) {
+	case 0:
+	case 8:
+		parentID := model.ID(binary.BigEndian.Uint64(s.ParentId))
+		span.ParentID = &parentID
+	default:
+		return nil, false
+	}
+
+	for _, annotation := range s.Annotations {
+		span.Annotations = append(span.Annotations, model.Annotation{
+			Timestamp: time.Unix(0, int64(annotation.Timestamp)*int64(time.Microsecond)),
+			Value:     annotation.Value,
+		})
+	}
+	return span, true
+}
+
+func protoKindToModel(kind zipkin_proto3.Span_Kind) model.Kind {
+	switch kind {
+	case zipkin_proto3.Span_CLIENT:
+		return model.Client
+	case zipkin_proto3.Span_SERVER:
+		return model.Server
+	case zipkin_proto3.Span_PRODUCER:
+		return model.Producer
+	case zipkin_proto3.Span_CONSUMER:
+		return model.Consumer
+	}
+	return model.Undetermined
+}
+
+func protoEndpointToModel(endpoint *zipkin_proto3.Endpoint) *model.Endpoint {
+	if endpoint == nil {
+		return nil
+	}
+	e := &model.Endpoint{
+		ServiceName: endpoint.ServiceName,
+		Port:        uint16(endpoint.Port),
+	}
+	if len(endpoint.Ipv4) == net.IPv4len {
+		e.IPv4 = net.IP(endpoint.Ipv4)
+	}
+	if len(endpoint.Ipv6) == net.IPv6len {
+		e.IPv6 = net.IP(endpoint.Ipv6)
+	}
+	if e.Empty() {
+		return nil
+	}
+	return e
+}
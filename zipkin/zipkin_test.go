@@ -0,0 +1,190 @@
+package zipkin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/otlp"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const testJSONSpans = `[
+  {
+    "traceId": "0000000000000000463ac35c9f6413ad",
+    "id": "72485a3953bb6124",
+    "name": "get /api",
+    "kind": "SERVER",
+    "timestamp": 1630500000000000,
+    "duration": 1500,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "10.0.0.1", "port": 8080},
+    "remoteEndpoint": {"ipv4": "10.0.0.2", "port": 51234},
+    "annotations": [{"timestamp": 1630500000000500, "value": "ws"}],
+    "tags": {"http.method": "GET", "error": "connection reset"}
+  },
+  {
+    "traceId": "5af7183fb1d4cf5f463ac35c9f6413ad",
+    "parentId": "72485a3953bb6124",
+    "id": "0102030405060708",
+    "name": "query",
+    "kind": "CLIENT",
+    "timestamp": 1630500000000100,
+    "duration": 800,
+    "localEndpoint": {"serviceName": "backend"},
+    "remoteEndpoint": {"serviceName": "postgres"}
+  },
+  {
+    "traceId": "463ac35c9f6413ad",
+    "id": "0a0b0c0d0e0f0102",
+    "name": "render",
+    "timestamp": 1630500000000200,
+    "duration": 100,
+    "localEndpoint": {"serviceName": "frontend"},
+    "tags": {"otel.status_code": "OK"}
+  }
+]`
+
+func TestTranslateJSONSpans(t *testing.T) {
+	result, err := TranslateJSONSpans([]byte(testJSONSpans), otlp.RequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+
+	frontend := result.Batches[0]
+	assert.Equal(t, "frontend", frontend.Dataset)
+	assert.Equal(t, "backend", result.Batches[1].Dataset)
+	require.Equal(t, 2, len(frontend.Events))
+
+	server := frontend.Events[0]
+	assert.Equal(t, time.Unix(1630500000, 0).UTC(), server.Timestamp)
+	assert.Equal(t, "463ac35c9f6413ad", server.Attributes["traceTraceID"])
+	assert.Equal(t, "72485a3953bb6124", server.Attributes["traceSpanID"])
+	assert.Equal(t, "server", server.Attributes["spanKind"])
+	assert.Equal(t, "get /api", server.Attributes["spanName"])
+	assert.Equal(t, 1.5, server.Attributes["durationMs"])
+	assert.Equal(t, int64(1630500000000000000), server.Attributes["startTime"])
+	assert.Equal(t, int64(1630500000001500000), server.Attributes["endTime"])
+	assert.Equal(t, trace.Status_STATUS_CODE_ERROR, server.Attributes["statusCode"])
+	assert.Equal(t, "connection reset", server.Attributes["statusMessage"])
+	assert.Equal(t, true, server.Attributes["error"])
+	assert.Equal(t, 1, server.Attributes["spanNumEvents"])
+	assert.Equal(t, 0, server.Attributes["spanNumLinks"])
+	assert.Equal(t, map[string]interface{}{"service.name": "frontend"}, server.Attributes["resourceAttributes"])
+	assert.Equal(t, map[string]interface{}{
+		"http.method":   "GET",
+		"error":         "connection reset",
+		"net.host.ip":   "10.0.0.1",
+		"net.host.port": int64(8080),
+		"net.peer.ip":   "10.0.0.2",
+		"net.peer.port": int64(51234),
+	}, server.Attributes["spanAttributes"])
+	assert.Equal(t, map[string]interface{}{}, server.Attributes["eventAttributes"])
+	_, ok := server.Attributes["traceParentID"]
+	assert.False(t, ok)
+
+	local := frontend.Events[1]
+	assert.Equal(t, "unspecified", local.Attributes["spanKind"])
+	assert.Equal(t, "463ac35c9f6413ad", local.Attributes["traceTraceID"])
+	assert.Equal(t, trace.Status_STATUS_CODE_OK, local.Attributes["statusCode"])
+	assert.Equal(t, false, local.Attributes["error"])
+
+	client := result.Batches[1].Events[0]
+	assert.Equal(t, "5af7183fb1d4cf5f463ac35c9f6413ad", client.Attributes["traceTraceID"])
+	assert.Equal(t, "72485a3953bb6124", client.Attributes["traceParentID"])
+	assert.Equal(t, "postgres", client.Attributes["spanAttributes"].(map[string]interface{})["peer.service"])
+}
+
+func TestTranslateJSONSpansDatasetFallback(t *testing.T) {
+	result, err := TranslateJSONSpans([]byte(testJSONSpans), otlp.RequestInfo{Dataset: "dataset"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "dataset", result.Batches[0].Dataset)
+	assert.Equal(t, "dataset", result.Batches[1].Dataset)
+
+	data := []byte(`[{"traceId": "463ac35c9f6413ad", "id": "72485a3953bb6124", "name": "op"}]`)
+
+	result, err = TranslateJSONSpans(data, otlp.RequestInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "unknown_service", result.Batches[0].Dataset)
+	assert.Equal(t, map[string]interface{}{}, result.Batches[0].Events[0].Attributes["resourceAttributes"])
+}
+
+func TestTranslateJSONSpansInvalid(t *testing.T) {
+	for _, data := range []string{`{}`, `[{"traceId": "zz", "id": "72485a3953bb6124"}]`, `[{"traceId": "463ac35c9f6413ad"}]`} {
+		_, err := TranslateJSONSpans([]byte(data), otlp.RequestInfo{})
+		assert.Equal(t, otlp.ErrFailedParseBody, err, data)
+	}
+}
+
+func TestTranslateProtoSpans(t *testing.T) {
+	list := &zipkin_proto3.ListOfSpans{Spans: []*zipkin_proto3.Span{
+		{
+			TraceId:       []byte{0x46, 0x3a, 0xc3, 0x5c, 0x9f, 0x64, 0x13, 0xad},
+			Id:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			ParentId:      []byte{8, 7, 6, 5, 4, 3, 2, 1},
+			Name:          "get",
+			Kind:          zipkin_proto3.Span_PRODUCER,
+			Timestamp:     1630500000000000,
+			Duration:      2000,
+			LocalEndpoint: &zipkin_proto3.Endpoint{ServiceName: "queue", Ipv4: []byte{10, 0, 0, 1}},
+			Annotations:   []*zipkin_proto3.Annotation{{Timestamp: 1630500000001000, Value: "flushed"}},
+			Tags:          map[string]string{"messaging.system": "kafka", "error": ""},
+		},
+		{
+			TraceId: append(make([]byte, 8), 1, 2, 3, 4, 5, 6, 7, 8),
+			Id:      []byte{1, 1, 1, 1, 1, 1, 1, 1},
+			Name:    "second",
+		},
+	}}
+	data, err := proto.Marshal(list)
+	require.NoError(t, err)
+
+	result, err := TranslateProtoSpans(data, otlp.RequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "queue", result.Batches[0].Dataset)
+	assert.Equal(t, "unknown_service", result.Batches[1].Dataset)
+
+	events := result.Batches[0].Events
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, "463ac35c9f6413ad", events[0].Attributes["traceTraceID"])
+	assert.Equal(t, "0102030405060708", events[0].Attributes["traceSpanID"])
+	assert.Equal(t, "0807060504030201", events[0].Attributes["traceParentID"])
+	assert.Equal(t, "producer", events[0].Attributes["spanKind"])
+	assert.Equal(t, 2.0, events[0].Attributes["durationMs"])
+	assert.Equal(t, true, events[0].Attributes["error"])
+	assert.Equal(t, 1, events[0].Attributes["spanNumEvents"])
+	_, ok := events[0].Attributes["statusMessage"]
+	assert.False(t, ok)
+	assert.Equal(t, "10.0.0.1", events[0].Attributes["spanAttributes"].(map[string]interface{})["net.host.ip"])
+
+	assert.Equal(t, "0102030405060708", result.Batches[1].Events[0].Attributes["traceTraceID"])
+}
+
+func TestTranslateProtoSpansInvalidID(t *testing.T) {
+	data, err := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: []*zipkin_proto3.Span{{TraceId: []byte{1, 2, 3}, Id: []byte{1, 2, 3, 4, 5, 6, 7, 8}}}})
+	require.NoError(t, err)
+	_, err = TranslateProtoSpans(data, otlp.RequestInfo{})
+	assert.Equal(t, otlp.ErrFailedParseBody, err)
+}
+
+func TestTranslateTraceReqFromReader(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testJSONSpans))
+	w.Close()
+
+	ri := otlp.RequestInfo{ContentType: "application/json", ContentEncoding: "gzip"}
+	result, err := TranslateTraceReqFromReader(ioutil.NopCloser(&buf), ri)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(result.Batches))
+
+	ri.ContentType = "text/plain"
+	_, err = TranslateTraceReqFromReader(ioutil.NopCloser(bytes.NewReader(nil)), ri)
+	assert.Equal(t, otlp.ErrInvalidContentType, err)
+}